@@ -0,0 +1,56 @@
+// Command import bulk imports buses or schedules from a CSV or JSON file,
+// validating every row the same way the /admin/import endpoint does.
+//
+//	go run ./cmd/import -kind buses -file trips.csv -dry-run
+package main
+
+import (
+	configs "busapp/database"
+	helper "busapp/helpers"
+	"busapp/models"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	kind := flag.String("kind", models.ImportBuses, "what the file holds: buses or schedules")
+	path := flag.String("file", "", "CSV or JSON file to import")
+	format := flag.String("format", "", "csv or json, taken from the file extension when empty")
+	dryRun := flag.Bool("dry-run", false, "only validate the file without inserting anything")
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*path)), ".")
+	}
+
+	configs.ConnectDB()
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening file:", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	result, err := helper.ImportFromReader(context.Background(), *kind, *format, file, *dryRun)
+	if result != nil {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(output))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import failed:", err)
+		os.Exit(1)
+	}
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
@@ -4,82 +4,166 @@ import (
 	helper "busapp/helpers"
 	"busapp/models"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func AddBus(c *gin.Context) {
-	// Extract admin information from the token or any other identifier
-	roleFromToken, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role information not found"})
+	// Extract bus information from the request
+	var addBusRequest models.Bus
+	if err := c.BindJSON(&addBusRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	// Check if the user making the request is an admin
-	isAdmin, _ := roleFromToken.(string)
-	if isAdmin != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unauthorized access"})
+	// Every trip runs along a route
+	if addBusRequest.Route_id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_id is required"})
+		return
+	}
+	route, err := helper.GetRouteByRouteId(c, addBusRequest.Route_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking route existence"})
+		return
+	}
+	if route == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Route with the provided route_id not found"})
 		return
 	}
 
-	// Extract bus information from the request
-	var addBusRequest models.Bus
-	if err := c.BindJSON(&addBusRequest); err != nil {
+	// Build the trip with its seat map
+	newBus, err := helper.NewBus(route, addBusRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Insert the new user into the database
+	_, err = busCollection.InsertOne(c, newBus)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Another bus on the route departs at the same time"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add Bus: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bus added successfully"})
+
+}
+
+// EditBus is the API endpoint to change the schedule, route, fare or seats of a bus (requires buses:manage)
+func EditBus(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	var editBusRequest helper.BusEditRequest
+	if err := c.BindJSON(&editBusRequest); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
 
-	// Check if the required fields are present
-	if addBusRequest.SeatsTotal < 0 || addBusRequest.SeatsTotal > 45 || addBusRequest.Date == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Total seats and date are required"})
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
 		return
 	}
 
-	createdAt := time.Now()
-	updatedAt := time.Now()
+	updatedBus, err := helper.EditBus(c, bus, editBusRequest)
+	if errors.Is(err, helper.ErrInvalidBusEdit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err == helper.ErrBusChanged {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update bus: %v", err)})
+		return
+	}
 
-	// Create a new user object
-	newBus := models.Bus{
-		ID:         primitive.NewObjectID(),
-		Bus_id:     primitive.NewObjectID().Hex(),
-		Date:       addBusRequest.Date,
-		SeatsTotal: addBusRequest.SeatsTotal,
-		Created_at: createdAt,
-		Updated_at: updatedAt,
-		// Add other fields as needed
+	c.JSON(http.StatusOK, gin.H{"message": "Bus updated successfully", "bus": updatedBus})
+}
+
+// AdminCancelBus is the API endpoint to cancel a trip and refund its bookings (requires buses:manage)
+func AdminCancelBus(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
 	}
 
-	// Insert the new user into the database
-	_, err := busCollection.InsertOne(c, newBus)
+	bus, err := helper.GetBusByBusId(c, busID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add Bus: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Bus added successfully"})
+	refunded, err := helper.CancelTrip(c, busID, models.TripStatusEvent{Note: c.Query("note"), By: c.GetString("uid")})
+	if err == helper.ErrInvalidTransition {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s bus cannot be cancelled", helper.TripStatus(bus))})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to cancel bus: %v", err)})
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Bus cancelled successfully", "refunded_bookings": refunded})
 }
 
-func Adduser(c *gin.Context) {
-	// Extract admin information from the token or any other identifier
-	roleFromToken, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role information not found"})
+// AdminDeleteBus is the API endpoint to delete a bus without bookings (requires buses:manage)
+func AdminDeleteBus(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
 		return
 	}
 
-	// Check if the user making the request is an admin
-	isAdmin, _ := roleFromToken.(string)
-	if isAdmin != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unauthorized access"})
+	err = helper.DeleteBus(c, busID)
+	if err == helper.ErrBusInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bus has bookings, cancel it instead"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete bus: %v", err)})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Bus deleted successfully"})
+}
+
+func Adduser(c *gin.Context) {
 	// Extract user information from the request
 	var addUserRequest models.User
 	if err := c.BindJSON(&addUserRequest); err != nil {
@@ -92,6 +176,14 @@ func Adduser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Email,Password,phoneNumber,username and role are required"})
 		return
 	}
+	if !helper.ValidRole(addUserRequest.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of customer, agent, support, operator or admin"})
+		return
+	}
+	if !helper.CanAssignRole(c.GetString("role"), addUserRequest.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot give a role above your own"})
+		return
+	}
 
 	// Check if the email already exists
 	existingUserMail, err := helper.GetUserByEmail(c, addUserRequest.Email)
@@ -141,33 +233,103 @@ func Adduser(c *gin.Context) {
 		// Add other fields as needed
 	}
 
+	// The role given to the user is audited before the user is stored
+	roleChange, err := helper.RecordRoleChange(c, models.RoleChange{
+		User_id: newUser.UserID,
+		To_role: newUser.Role,
+		By:      c.GetString("uid"),
+		By_role: c.GetString("role"),
+		Reason:  "user created",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to record the role of the user: %v", err)})
+		return
+	}
+
 	// Insert the new user into the database
 	_, err = userCollection.InsertOne(c, newUser)
 	if err != nil {
+		helper.FinishRoleChange(c, roleChange, models.RoleChangeFailed)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add user: %v", err)})
 		return
 	}
 
+	helper.FinishRoleChange(c, roleChange, models.RoleChangeApplied)
+
 	c.JSON(http.StatusOK, gin.H{"message": "User added successfully"})
 
 }
 
-// DeleteUserHandler is the API endpoint to delete a user by user_id (admin only)
-func AdminDeleteUser(c *gin.Context) {
-	// Extract admin information from the token or any other identifier
-	roleFromToken, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role information not found"})
+// RoleRequest is the payload giving a user a new role
+type RoleRequest struct {
+	Role   string `json:"role"`
+	Reason string `json:"reason"`
+}
+
+// AdminAssignRole is the API endpoint giving a user a new role, never one
+// above the caller's own (requires users:manage)
+func AdminAssignRole(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id parameter is required"})
+		return
+	}
+
+	var request RoleRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if !helper.ValidRole(request.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be one of customer, agent, support, operator or admin"})
 		return
 	}
 
-	// Check if the user making the request is an admin
-	isAdmin, _ := roleFromToken.(string)
-	if isAdmin != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unauthorized access"})
+	user, err := helper.AssignRole(c, userID, models.RoleChange{
+		To_role: request.Role,
+		By:      c.GetString("uid"),
+		By_role: c.GetString("role"),
+		Reason:  request.Reason,
+	})
+	if err == helper.ErrRoleAboveAssigner || err == helper.ErrOwnRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err == helper.ErrRoleChanged {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role was changed by someone else, try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to assign role: %v", err)})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User with the provided user_id not found"})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned successfully", "user_id": user.UserID, "role": helper.UserRole(user)})
+}
+
+// AdminGetRoleChanges is the API endpoint listing the role changes of a user (requires users:manage)
+func AdminGetRoleChanges(c *gin.Context) {
+	userID := c.Query("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id parameter is required"})
+		return
+	}
+
+	changes, err := helper.GetRoleChanges(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving role changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role_changes": changes})
+}
+
+// DeleteUserHandler is the API endpoint to delete a user by user_id (requires users:manage)
+func AdminDeleteUser(c *gin.Context) {
 	// Get the user_id to be deleted from the request
 	user_id := c.Query("user_id")
 	if user_id == "" {
@@ -186,18 +348,6 @@ func AdminDeleteUser(c *gin.Context) {
 }
 
 func AdminGetAllCustomers(c *gin.Context) {
-	// Extract admin information from the token or any other identifier
-	roleFromToken, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role information not found"})
-		return
-	}
-	// Check if the user making the request is an admin
-	isAdmin, _ := roleFromToken.(string)
-	if isAdmin != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unauthorized access"})
-		return
-	}
 	users, err := helper.GetAllCustomersFromDatabase(context.Background())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving customers"})
@@ -209,24 +359,124 @@ func AdminGetAllCustomers(c *gin.Context) {
 }
 
 func AdminGetAllUsers(c *gin.Context) {
-	// Extract admin information from the token or any other identifier
-	roleFromToken, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Role information not found"})
+	users, err := helper.GetAllUsersFromDatabase(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users})
+
+}
+
+// AdminGetManifest is the API endpoint streaming the boarding manifest of a bus as CSV or PDF (requires manifests:view)
+func AdminGetManifest(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
 		return
 	}
-	// Check if the user making the request is an admin
-	isAdmin, _ := roleFromToken.(string)
-	if isAdmin != "admin" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Unauthorized access"})
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or pdf"})
 		return
 	}
-	users, err := helper.GetAllUsersFromDatabase(context.Background())
+
+	bus, err := helper.GetBusByBusId(c, busID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving users"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	rows, err := helper.GetManifest(c, bus)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building manifest"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=manifest-"+bus.Bus_id+"."+format)
+	if format == "pdf" {
+		c.Header("Content-Type", "application/pdf")
+		err = helper.WriteManifestPDF(c.Writer, bus, rows)
+	} else {
+		c.Header("Content-Type", "text/csv")
+		err = helper.WriteManifestCSV(c.Writer, rows)
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// AdminGetAllBuses is the API endpoint listing buses page by page with their load factor and revenue (requires buses:view)
+func AdminGetAllBuses(c *gin.Context) {
+	query := helper.BusListQuery{
+		Date_from: c.Query("date_from"),
+		Date_to:   c.Query("date_to"),
+		Route_id:  c.Query("route_id"),
+		Status:    c.Query("status"),
+		Sort:      c.DefaultQuery("sort", helper.BusSortDeparture),
+		Cursor:    c.Query("cursor"),
+	}
+	for _, date := range []string{query.Date_from, query.Date_to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(helper.DateFormat, date); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date_from and date_to must be formatted as YYYY-MM-DD"})
+			return
+		}
+	}
+	if query.Sort != helper.BusSortDeparture && query.Sort != helper.BusSortFare && query.Sort != helper.BusSortLoadFactor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be departure, fare or load_factor"})
+		return
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+
+	var err error
+	if value := c.Query("min_occupancy"); value != "" {
+		percentage, err := strconv.ParseFloat(value, 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_occupancy must be a percentage between 0 and 100"})
+			return
+		}
+		query.Min_occupancy = &percentage
+	}
+	if value := c.Query("max_occupancy"); value != "" {
+		percentage, err := strconv.ParseFloat(value, 64)
+		if err != nil || percentage < 0 || percentage > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_occupancy must be a percentage between 0 and 100"})
+			return
+		}
+		query.Max_occupancy = &percentage
+	}
+	if value := c.Query("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+	}
+
+	buses, next, err := helper.ListBuses(c, query)
+	if err == helper.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving buses"})
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{"buses": buses, "next_cursor": next})
 }
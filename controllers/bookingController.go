@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookSeatsRequest is the request payload for turning a seat hold into a booking
+type BookSeatsRequest struct {
+	Hold_id string `json:"hold_id"`
+}
+
+// BookSeats is the API endpoint to check out the seats held by the logged in user
+func BookSeats(c *gin.Context) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	var request BookSeatsRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if request.Hold_id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hold_id is required"})
+		return
+	}
+
+	booking, intent, err := helper.CreateBooking(c, request.Hold_id, userIdFromToken.(string))
+	if err == helper.ErrHoldNotActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Hold has expired or was already used, please select your seats again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to book seats: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Booking created, complete the payment to confirm it", "booking": booking, "payment": intent})
+}
+
+// GetSeatMap is the API endpoint returning the seat layout of a bus with the state of every seat
+func GetSeatMap(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bus_id":       bus.Bus_id,
+		"layout":       bus.Layout,
+		"seats_total":  bus.SeatsTotal,
+		"seats_booked": bus.SeatsBooked,
+		"seats_held":   bus.SeatsHeld,
+		"seats":        bus.Seats,
+	})
+}
+
+// StreamSeatMap is the public API endpoint streaming the seat map of a bus as
+// Server-Sent Events: a "seats" event with every seat, then one with the
+// seats whose status changed each time seats are held, booked or released
+func StreamSeatMap(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	updates, unsubscribe, err := helper.SubscribeSeats(c, bus.Bus_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to follow seat map: %v", err)})
+		return
+	}
+	defer unsubscribe()
+
+	// Keep proxies from closing an idle stream
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case update, open := <-updates:
+			if !open {
+				return false // Fell behind, the client reconnects for a fresh seat map
+			}
+			c.SSEvent("seats", update)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// CancelBooking is the API endpoint for the logged in user to cancel a booking and get a refund
+func CancelBooking(c *gin.Context) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	bookingID := c.Query("booking_id")
+	if bookingID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "booking_id parameter is required"})
+		return
+	}
+
+	booking, err := helper.CancelBooking(c, bookingID, userIdFromToken.(string))
+	switch err {
+	case nil:
+	case helper.ErrBookingNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking with the provided booking_id not found"})
+		return
+	case helper.ErrBookingNotCancellable:
+		c.JSON(http.StatusConflict, gin.H{"error": "Only confirmed bookings can be cancelled"})
+		return
+	case helper.ErrTripDeparted:
+		c.JSON(http.StatusConflict, gin.H{"error": "Bookings cannot be cancelled after the trip has departed"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to cancel booking: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Booking cancelled successfully",
+		"refund_amount": booking.Refund_amount,
+		"booking":       booking,
+	})
+}
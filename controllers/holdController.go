@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HoldSeatsRequest is the request payload for holding seats on a bus
+type HoldSeatsRequest struct {
+	Bus_id       string             `json:"bus_id"`
+	Seats        int                `json:"seats"`
+	Seat_numbers []string           `json:"seat_numbers"`
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	Passengers   []models.Passenger `json:"passengers"`
+}
+
+// HoldSeats is the API endpoint to hold seats on a bus for the logged in user until checkout
+func HoldSeats(c *gin.Context) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	var request HoldSeatsRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if request.Bus_id == "" || (request.Seats <= 0 && len(request.Seat_numbers) == 0 && len(request.Passengers) == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id and either passengers, seat_numbers or a positive number of seats are required"})
+		return
+	}
+
+	// Orders with passenger details take their seats from the passengers
+	if len(request.Passengers) > 0 {
+		if len(request.Seat_numbers) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Give seat numbers on the passengers instead of seat_numbers"})
+			return
+		}
+		if err := helper.ValidatePassengers(request.Passengers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		request.Seat_numbers = helper.PassengerSeatNumbers(request.Passengers)
+	}
+
+	// Make sure the bus exists before trying to hold seats on it
+	bus, err := helper.GetBusByBusId(c, request.Bus_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking bus existence"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	// Check the requested seat numbers against the bus seat map
+	if len(request.Seat_numbers) > 0 {
+		if len(bus.Seats) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This bus has no seat map, hold by number of seats instead"})
+			return
+		}
+		if err := helper.ValidateSeatNumbers(bus, request.Seat_numbers); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	hold, err := helper.CreateHold(c, bus, userIdFromToken.(string), helper.HoldRequest{
+		Seats:        request.Seats,
+		Seat_numbers: request.Seat_numbers,
+		From:         request.From,
+		To:           request.To,
+		Passengers:   request.Passengers,
+	})
+	if err == helper.ErrTripNotBookable {
+		c.JSON(http.StatusConflict, gin.H{"error": "This trip is not open for booking"})
+		return
+	}
+	if err == helper.ErrInvalidSegment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This bus does not travel between the requested cities"})
+		return
+	}
+	if err == helper.ErrNotEnoughSeats {
+		c.JSON(http.StatusConflict, gin.H{"error": "The requested seats are not available on this bus"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to hold seats: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Seats held successfully", "hold": hold})
+}
+
+// ReleaseSeatHold is the API endpoint to give held seats back before checkout
+func ReleaseSeatHold(c *gin.Context) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	holdID := c.Query("hold_id")
+	if holdID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hold_id parameter is required"})
+		return
+	}
+
+	err := helper.ReleaseHold(c, holdID, userIdFromToken.(string))
+	if err == helper.ErrHoldNotActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Hold has expired or was already used"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to release hold: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold released successfully"})
+}
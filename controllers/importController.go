@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes is the largest import file accepted
+const maxImportBytes = 10 << 20
+
+// AdminImport is the API endpoint to bulk import buses or schedules from a CSV or JSON file (requires buses:manage).
+// The file is either the request body or a multipart "file" field.
+func AdminImport(c *gin.Context) {
+	kind := c.DefaultQuery("kind", models.ImportBuses)
+	format := c.Query("format")
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading file"})
+			return
+		}
+		defer file.Close()
+		reader = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		}
+	}
+	if format == "" {
+		format = "json"
+		if c.ContentType() == "text/csv" {
+			format = "csv"
+		}
+	}
+
+	result, err := helper.ImportFromReader(c, kind, format, reader, dryRun)
+	if errors.Is(err, helper.ErrInvalidImport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert rows: " + err.Error(), "result": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
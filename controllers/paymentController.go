@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/payments"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handlePaymentEvent confirms or fails the booking of a verified payment event
+func handlePaymentEvent(c *gin.Context, event *payments.WebhookEvent) {
+	var err error
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		_, err = helper.ConfirmBookingPayment(c, event.Intent_id)
+	case payments.EventPaymentFailed:
+		_, err = helper.FailBookingPayment(c, event.Intent_id)
+	}
+	if err == helper.ErrBookingNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No booking found for this payment"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to process payment: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Payment processed successfully"})
+}
+
+// PaymentWebhook is the public API endpoint the payment provider calls when a payment succeeds or fails
+func PaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	event, err := helper.PaymentProvider.VerifyWebhook(payload, c.Request.Header.Get("Payment-Signature"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	handlePaymentEvent(c, event)
+}
+
+// CompleteFakePayment is the API endpoint that plays the customer paying
+// through the fake provider, so the payment flow can be tried offline. It is
+// only registered when PAYMENT_PROVIDER is explicitly set to fake.
+func CompleteFakePayment(c *gin.Context) {
+	fake, ok := helper.PaymentProvider.(*payments.FakeProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payments are not using the fake provider"})
+		return
+	}
+
+	intentID := c.Query("intent_id")
+	if intentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "intent_id parameter is required"})
+		return
+	}
+
+	// Only the customer paying for the booking completes its payment
+	booking, err := helper.GetBookingByPaymentIntentId(c, intentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving booking"})
+		return
+	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No booking found for this payment"})
+		return
+	}
+	if booking.User_id != c.GetString("uid") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot complete the payment of another user's booking"})
+		return
+	}
+
+	succeeded, err := strconv.ParseBool(c.DefaultQuery("succeeded", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "succeeded must be true or false"})
+		return
+	}
+
+	payload, signature, err := fake.Complete(intentID, succeeded)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Deliver the webhook the same way the provider would
+	event, err := fake.VerifyWebhook(payload, signature)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	handlePaymentEvent(c, event)
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddFare is the API endpoint to set the base fare of a route segment and seat class (requires pricing:manage)
+func AddFare(c *gin.Context) {
+	var addFareRequest models.Fare
+	if err := c.BindJSON(&addFareRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidateFare(addFareRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The segment must follow the direction of the route
+	route, err := helper.GetRouteByRouteId(c, addFareRequest.Route_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking route existence"})
+		return
+	}
+	if route == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Route with the provided route_id not found"})
+		return
+	}
+	from, to, err := helper.ResolveSegment(c, &models.Bus{Route_id: route.Route_id}, addFareRequest.From, addFareRequest.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The route does not travel between the requested cities"})
+		return
+	}
+
+	newFare := models.Fare{
+		ID:         primitive.NewObjectID(),
+		Route_id:   route.Route_id,
+		From:       from,
+		To:         to,
+		Seat_class: addFareRequest.Seat_class,
+		Base_fare:  addFareRequest.Base_fare,
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	newFare.Fare_id = newFare.ID.Hex()
+
+	if err := helper.UpsertFare(c, newFare); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add fare: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fare saved successfully"})
+}
+
+// AdminGetFares is the API endpoint to list the base fares of a route (requires pricing:manage)
+func AdminGetFares(c *gin.Context) {
+	routeID := c.Query("route_id")
+	if routeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_id parameter is required"})
+		return
+	}
+
+	fares, err := helper.GetFaresByRouteId(c, routeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving fares"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fares": fares})
+}
+
+// AddPricingRule is the API endpoint to create a surcharge or discount rule (requires pricing:manage)
+func AddPricingRule(c *gin.Context) {
+	var addRuleRequest models.PricingRule
+	if err := c.BindJSON(&addRuleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidatePricingRule(addRuleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newRule := addRuleRequest
+	newRule.ID = primitive.NewObjectID()
+	newRule.Rule_id = newRule.ID.Hex()
+	newRule.Active = true
+	newRule.Created_at = time.Now()
+
+	if err := helper.InsertPricingRule(c, newRule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add pricing rule: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pricing rule added successfully", "rule_id": newRule.Rule_id})
+}
+
+// AdminGetPricingRules is the API endpoint to list every pricing rule (requires pricing:manage)
+func AdminGetPricingRules(c *gin.Context) {
+	rules, err := helper.GetAllPricingRulesFromDatabase(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving pricing rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// AdminDeletePricingRule is the API endpoint to delete a pricing rule (requires pricing:manage)
+func AdminDeletePricingRule(c *gin.Context) {
+	ruleID := c.Query("rule_id")
+	if ruleID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rule_id parameter is required"})
+		return
+	}
+
+	if err := helper.DeletePricingRuleByRuleId(c, ruleID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete pricing rule: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pricing rule deleted successfully"})
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AddRoute is the API endpoint to create a route with its stops (requires routes:manage)
+func AddRoute(c *gin.Context) {
+	// Extract route information from the request
+	var addRouteRequest models.Route
+	if err := c.BindJSON(&addRouteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidateRoute(addRouteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stops := addRouteRequest.Stops
+	newRoute := models.Route{
+		ID:          primitive.NewObjectID(),
+		Name:        addRouteRequest.Name,
+		Origin:      stops[0].City,
+		Destination: stops[len(stops)-1].City,
+		Stops:       stops,
+		Created_at:  time.Now(),
+		Updated_at:  time.Now(),
+	}
+	newRoute.Route_id = newRoute.ID.Hex()
+
+	if err := helper.InsertRoute(c, newRoute); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add route: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route added successfully", "route_id": newRoute.Route_id})
+}
+
+// AdminGetAllRoutes is the API endpoint to list every route (requires routes:manage)
+func AdminGetAllRoutes(c *gin.Context) {
+	routes, err := helper.GetAllRoutesFromDatabase(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving routes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"routes": routes})
+}
+
+// EditRoute is the API endpoint to change the name and stops of a route (requires routes:manage)
+func EditRoute(c *gin.Context) {
+	routeID := c.Query("route_id")
+	if routeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_id parameter is required"})
+		return
+	}
+
+	var editRouteRequest models.Route
+	if err := c.BindJSON(&editRouteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidateRoute(editRouteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stops := editRouteRequest.Stops
+	editRouteRequest.Origin = stops[0].City
+	editRouteRequest.Destination = stops[len(stops)-1].City
+
+	if err := helper.UpdateRouteByRouteId(c, routeID, editRouteRequest); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update route: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route updated successfully"})
+}
+
+// AdminDeleteRoute is the API endpoint to delete a route that has no trips (requires routes:manage)
+func AdminDeleteRoute(c *gin.Context) {
+	routeID := c.Query("route_id")
+	if routeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_id parameter is required"})
+		return
+	}
+
+	err := helper.DeleteRouteByRouteId(c, routeID)
+	if err == helper.ErrRouteNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route with the provided route_id not found"})
+		return
+	}
+	if err == helper.ErrRouteInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Route is still used by bus trips"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to delete route: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Route deleted successfully"})
+}
+
+// AdminGetRefundPolicies is the API endpoint to list every route with the
+// refund policy that applies to it, so routes can be found without
+// routes:manage (requires refundpolicy:manage)
+func AdminGetRefundPolicies(c *gin.Context) {
+	routes, err := helper.GetAllRoutesFromDatabase(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving routes"})
+		return
+	}
+
+	policies := make([]models.RoutePolicy, 0, len(routes))
+	for i := range routes {
+		route := &routes[i]
+		policies = append(policies, models.RoutePolicy{
+			Route_id:      route.Route_id,
+			Name:          route.Name,
+			Origin:        route.Origin,
+			Destination:   route.Destination,
+			Refund_policy: helper.RouteRefundPolicy(route),
+			Default:       len(route.Refund_policy) == 0,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"routes": policies})
+}
+
+// SetRouteRefundPolicy is the API endpoint to configure the cancellation refund tiers of a route (requires refundpolicy:manage)
+func SetRouteRefundPolicy(c *gin.Context) {
+	routeID := c.Query("route_id")
+	if routeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_id parameter is required"})
+		return
+	}
+
+	var request struct {
+		Tiers []models.RefundTier `json:"tiers"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidateRefundPolicy(request.Tiers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := helper.UpdateRouteRefundPolicy(c, routeID, request.Tiers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update refund policy: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Refund policy updated successfully"})
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddSchedule is the API endpoint to create a recurring schedule template (requires schedules:manage)
+func AddSchedule(c *gin.Context) {
+	var addScheduleRequest models.Schedule
+	if err := c.BindJSON(&addScheduleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := helper.ValidateSchedule(addScheduleRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, err := helper.GetRouteByRouteId(c, addScheduleRequest.Route_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking route existence"})
+		return
+	}
+	if route == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Route with the provided route_id not found"})
+		return
+	}
+
+	newSchedule, err := helper.NewSchedule(route, addScheduleRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := helper.InsertSchedule(c, newSchedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add schedule: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule added successfully", "schedule_id": newSchedule.Schedule_id})
+}
+
+// AdminGetAllSchedules is the API endpoint to list every schedule template (requires schedules:manage)
+func AdminGetAllSchedules(c *gin.Context) {
+	schedules, err := helper.GetAllSchedulesFromDatabase(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// GenerateScheduledTrips is the API endpoint to generate the trips of one
+// schedule, or of all active schedules when no schedule_id is given (requires schedules:manage)
+func GenerateScheduledTrips(c *gin.Context) {
+	scheduleID := c.Query("schedule_id")
+	if scheduleID == "" {
+		created, err := helper.GenerateTripsForAllSchedules(c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate trips: %v", err), "created": created})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Trips generated successfully", "created": created})
+		return
+	}
+
+	schedule, err := helper.GetScheduleByScheduleId(c, scheduleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving schedule"})
+		return
+	}
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule with the provided schedule_id not found"})
+		return
+	}
+
+	created, err := helper.GenerateTripsForSchedule(c, *schedule, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate trips: %v", err), "created": created})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trips generated successfully", "created": created})
+}
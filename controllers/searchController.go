@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseClockTime converts an "HH:MM" query value into minutes after midnight, or -1 when it is empty
+func parseClockTime(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// SearchTrips is the public API endpoint to find bus trips by origin, destination and date
+func SearchTrips(c *gin.Context) {
+	search := helper.TripSearch{
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Date:     c.Query("date"),
+		Bus_type: c.Query("bus_type"),
+		Sort:     c.DefaultQuery("sort", helper.SortByDeparture),
+	}
+	if search.From == "" || search.To == "" || search.Date == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from, to and date parameters are required"})
+		return
+	}
+	if _, err := time.Parse(helper.DateFormat, search.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a real date in YYYY-MM-DD format"})
+		return
+	}
+
+	passengers, err := strconv.Atoi(c.DefaultQuery("passengers", "1"))
+	if err != nil || passengers <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "passengers must be a positive number"})
+		return
+	}
+	search.Passengers = passengers
+
+	if search.Sort != helper.SortByDeparture && search.Sort != helper.SortByPrice && search.Sort != helper.SortByDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of departure, price or duration"})
+		return
+	}
+
+	search.Depart_after, err = parseClockTime(c.Query("depart_after"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "depart_after must be in HH:MM format"})
+		return
+	}
+	search.Depart_before, err = parseClockTime(c.Query("depart_before"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "depart_before must be in HH:MM format"})
+		return
+	}
+
+	trips, err := helper.SearchTrips(c, search)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching trips"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trips": trips})
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadTicket looks up the confirmed booking of the logged in user named in the
+// booking_id parameter and signs its ticket. It writes the error response
+// itself and returns ok=false when the ticket cannot be issued.
+func loadTicket(c *gin.Context) (booking *models.Booking, bus *models.Bus, passenger string, ticket string, ok bool) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	bookingID := c.Query("booking_id")
+	if bookingID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "booking_id parameter is required"})
+		return
+	}
+
+	booking, err := helper.GetBookingByBookingId(c, bookingID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving booking"})
+		return
+	}
+	if booking == nil || booking.User_id != userIdFromToken.(string) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking with the provided booking_id not found"})
+		return
+	}
+	if booking.Status != models.BookingConfirmed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tickets are only issued for confirmed bookings"})
+		return
+	}
+
+	bus, err = helper.GetBusByBusId(c, booking.Bus_id)
+	if err != nil || bus == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+
+	user, err := helper.GetUserByUid(c, booking.User_id)
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user details"})
+		return
+	}
+	passenger = user.Username
+	if len(booking.Passengers) > 0 {
+		var names []string
+		for _, traveller := range booking.Passengers {
+			names = append(names, traveller.Name)
+		}
+		passenger = strings.Join(names, ", ")
+	}
+
+	payload, err := helper.NewTicketPayload(booking, bus, passenger)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error building ticket"})
+		return
+	}
+	ticket, err = helper.SignTicket(payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing ticket"})
+		return
+	}
+
+	return booking, bus, passenger, ticket, true
+}
+
+// GetTicketQR is the API endpoint returning the signed e-ticket of a booking as a PNG QR code
+func GetTicketQR(c *gin.Context) {
+	_, _, _, ticket, ok := loadTicket(c)
+	if !ok {
+		return
+	}
+
+	png, err := helper.TicketQRCode(ticket, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating QR code"})
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// GetTicketPDF is the API endpoint returning a printable PDF ticket of a booking
+func GetTicketPDF(c *gin.Context) {
+	booking, bus, passenger, ticket, ok := loadTicket(c)
+	if !ok {
+		return
+	}
+
+	pdf, err := helper.TicketPDF(booking, bus, passenger, ticket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating PDF ticket"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=ticket-"+booking.Booking_id+".pdf")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// GetTicketPublicKey is the public API endpoint publishing the key conductors verify tickets with
+func GetTicketPublicKey(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"algorithm": "ed25519", "public_key": helper.TicketPublicKey()})
+}
+
+// VerifyTicketRequest is the scanned content of a ticket QR code
+type VerifyTicketRequest struct {
+	Ticket string `json:"ticket"`
+}
+
+// VerifyTicket is the API endpoint conductors check a scanned ticket with when
+// they are online. Besides the signature and validity it checks the booking is
+// still confirmed, which offline checks cannot (requires trips:operate).
+func VerifyTicket(c *gin.Context) {
+	var request VerifyTicketRequest
+	if err := c.BindJSON(&request); err != nil || request.Ticket == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ticket is required"})
+		return
+	}
+
+	payload, err := helper.VerifyTicket(request.Ticket, helper.TicketPublicKey(), time.Now())
+	if err == helper.ErrTicketExpired {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "reason": err.Error(), "ticket": payload})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "reason": err.Error()})
+		return
+	}
+
+	booking, err := helper.GetBookingByBookingId(c, payload.Booking_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving booking"})
+		return
+	}
+	if booking == nil || booking.Status != models.BookingConfirmed {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "reason": "booking is not confirmed", "ticket": payload})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": true, "ticket": payload})
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PingRequest is a position reported by the device on a bus
+type PingRequest struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Speed     float64   `json:"speed"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RecordTripPing is the API endpoint for the device on a bus to report its position (requires trips:operate)
+func RecordTripPing(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	var request PingRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format, timestamp must be RFC 3339"})
+		return
+	}
+	if request.Latitude < -90 || request.Latitude > 90 || request.Longitude < -180 || request.Longitude > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude are out of range"})
+		return
+	}
+	if request.Speed < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "speed cannot be negative"})
+		return
+	}
+	if request.Timestamp.IsZero() || request.Timestamp.After(time.Now().Add(2*time.Minute)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp is required and cannot be in the future"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	err = helper.RecordPing(c, bus, models.Ping{
+		Latitude:    request.Latitude,
+		Longitude:   request.Longitude,
+		Speed:       request.Speed,
+		Recorded_at: request.Timestamp.UTC(),
+	})
+	if err == helper.ErrTripNotRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "Trip is not running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording position"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Position recorded"})
+}
+
+// GetTripTracking is the API endpoint returning where a bus is and when it is expected at its stops
+func GetTripTracking(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	position, err := helper.GetTripPosition(c, bus)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error tracking trip"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tracking": position})
+}
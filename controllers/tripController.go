@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TripStatusRequest is the payload moving a trip to a new status
+type TripStatusRequest struct {
+	Status        string `json:"status"`
+	Note          string `json:"note"`
+	Delay_minutes int    `json:"delay_minutes"`
+}
+
+// UpdateTripStatus is the API endpoint moving a trip through its lifecycle (requires trips:operate)
+func UpdateTripStatus(c *gin.Context) {
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	var request TripStatusRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if !helper.ValidTripStatus(request.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of scheduled, delayed, boarding, departed, arrived or cancelled"})
+		return
+	}
+	if request.Status == models.TripDelayed && request.Delay_minutes <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delay_minutes must be positive for a delayed trip"})
+		return
+	}
+	if request.Status != models.TripDelayed && request.Delay_minutes != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delay_minutes can only be given for a delayed trip"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, busID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving bus"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	updatedBus, err := helper.TransitionTrip(c, busID, models.TripStatusEvent{
+		Status:        request.Status,
+		Delay_minutes: request.Delay_minutes,
+		Note:          request.Note,
+		By:            c.GetString("uid"),
+	})
+	if err == helper.ErrInvalidTransition {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A %s trip cannot become %s", helper.TripStatus(bus), request.Status)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to update trip status: %v", err)})
+		return
+	}
+	if updatedBus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Trip status updated successfully",
+		"status":         updatedBus.Status,
+		"delay_minutes":  updatedBus.Delay_minutes,
+		"status_history": updatedBus.Status_history,
+	})
+}
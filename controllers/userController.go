@@ -33,6 +33,9 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
+		// Everyone signs up as a customer, other roles are given by an admin
+		user.Role = models.RoleCustomer
+
 		// validationErr := validate.Struct(user)
 		// if validationErr != nil {
 		// 	c.JSON(http.StatusBadRequest, gin.H{"error": "2"})
@@ -118,12 +121,45 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		token, _ := helper.GenerateAllTokens(foundUser.Email, foundUser.Username, foundUser.UserID, foundUser.Role)
-		fmt.Println(token)
-		c.JSON(http.StatusOK, gin.H{"token": token})
+		tokens, err := helper.GenerateAllTokens(c, foundUser.Email, foundUser.Username, foundUser.UserID, foundUser.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
+			return
+		}
+		if err := helper.CreateSession(c, tokens.Session_id, foundUser.UserID, c.Request.UserAgent(), c.ClientIP()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token": tokens.Token, "refresh_token": tokens.Refresh_token, "expires_at": tokens.Expires_at, "session_id": tokens.Session_id})
 	}
 }
 
+// RefreshTokenRequest is the request payload for exchanging a refresh token
+type RefreshTokenRequest struct {
+	Refresh_token string `json:"refresh_token"`
+}
+
+// RefreshToken is the API endpoint exchanging a refresh token for a new access token and refresh token
+func RefreshToken(c *gin.Context) {
+	var request RefreshTokenRequest
+	if err := c.BindJSON(&request); err != nil || request.Refresh_token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	tokens, err := helper.RefreshTokens(c, request.Refresh_token)
+	if err == helper.ErrInvalidRefreshToken || err == helper.ErrRefreshTokenReused {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error refreshing tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokens.Token, "refresh_token": tokens.Refresh_token, "expires_at": tokens.Expires_at, "session_id": tokens.Session_id})
+}
+
 // HandleForgetPassword is the API endpoint for initiating the forgot password flow
 func ForgetPassword(c *gin.Context) {
 
@@ -357,3 +393,69 @@ func GetMyDetails(c *gin.Context) {
 func Hello(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
+
+// Logout is the API endpoint revoking the access token of the logged in user and ending its session
+func Logout(c *gin.Context) {
+	jti := c.GetString("jti")
+	if jti == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This token cannot be revoked, it expires on its own"})
+		return
+	}
+
+	err := helper.RevokeToken(c, jti, c.GetString("uid"), time.Unix(c.GetInt64("token_expires"), 0))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
+		return
+	}
+	if family := c.GetString("family"); family != "" {
+		if err := helper.RevokeTokenFamily(c, family); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetMySessions is the API endpoint listing the sessions the logged in user is signed in with
+func GetMySessions(c *gin.Context) {
+	sessions, err := helper.GetActiveSessions(c, c.GetString("uid"), c.GetString("family"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeMySession is the API endpoint signing the logged in user out of one of their sessions
+func RevokeMySession(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id parameter is required"})
+		return
+	}
+
+	err := helper.RevokeSession(c, c.GetString("uid"), sessionID)
+	if err == helper.ErrSessionNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session with the provided session_id not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeMyOtherSessions is the API endpoint signing the logged in user out everywhere but the current session
+func RevokeMyOtherSessions(c *gin.Context) {
+	revoked, err := helper.RevokeOtherSessions(c, c.GetString("uid"), c.GetString("family"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked successfully", "revoked": revoked})
+}
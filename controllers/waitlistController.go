@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	helper "busapp/helpers"
+	"busapp/models"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JoinWaitlistRequest is the request payload for joining the waitlist of a sold-out bus
+type JoinWaitlistRequest struct {
+	Bus_id string `json:"bus_id"`
+	Seats  int    `json:"seats"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// JoinWaitlist is the API endpoint for the logged in user to queue for seats on a sold-out bus
+func JoinWaitlist(c *gin.Context) {
+	// Get the user id and email from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+	emailFromToken, _ := c.Get("email")
+	email, _ := emailFromToken.(string)
+
+	var request JoinWaitlistRequest
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if request.Bus_id == "" || request.Seats <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id and a positive number of seats are required"})
+		return
+	}
+
+	bus, err := helper.GetBusByBusId(c, request.Bus_id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking bus existence"})
+		return
+	}
+	if bus == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bus with the provided bus_id not found"})
+		return
+	}
+	if request.Seats > bus.SeatsTotal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This bus does not have that many seats"})
+		return
+	}
+
+	entry, err := helper.JoinWaitlist(c, bus, userIdFromToken.(string), email, request.Seats, request.From, request.To)
+	switch err {
+	case nil:
+	case helper.ErrSeatsAvailable:
+		c.JSON(http.StatusConflict, gin.H{"error": "Seats are still available on this bus, hold them instead"})
+		return
+	case helper.ErrTripNotBookable:
+		c.JSON(http.StatusConflict, gin.H{"error": "This trip is not open for booking"})
+		return
+	case helper.ErrInvalidSegment:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This bus does not travel between the requested cities"})
+		return
+	case helper.ErrAlreadyWaitlisted:
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already on the waitlist for this bus"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to join waitlist: %v", err)})
+		return
+	}
+
+	position, err := helper.WaitlistPosition(c, entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving waitlist position"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Joined the waitlist successfully", "waitlist": entry, "position": position})
+}
+
+// GetWaitlistPosition is the API endpoint returning the logged in user's place on the waitlist of a bus
+func GetWaitlistPosition(c *gin.Context) {
+	// Get the user id from the token
+	userIdFromToken, exists := c.Get("uid")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Userid not found in the token"})
+		return
+	}
+
+	busID := c.Query("bus_id")
+	if busID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bus_id parameter is required"})
+		return
+	}
+
+	entry, err := helper.GetWaitlistEntry(c, busID, userIdFromToken.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving waitlist entry"})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not on the waitlist for this bus"})
+		return
+	}
+
+	// Promoted customers have a hold waiting for them instead of a position
+	if entry.Status != models.WaitlistWaiting {
+		c.JSON(http.StatusOK, gin.H{"status": entry.Status, "hold_id": entry.Hold_id})
+		return
+	}
+
+	position, err := helper.WaitlistPosition(c, entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving waitlist position"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": entry.Status, "position": position})
+}
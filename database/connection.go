@@ -10,23 +10,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Client instance
-var DB *mongo.Client = ConnectDB()
+// Client instance. It only reaches the database on first use, so packages
+// using it can be loaded without one, e.g. in unit tests.
+var DB *mongo.Client = newClient()
 
-func ConnectDB() *mongo.Client {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(EnvMongoURI()))
+func newClient() *mongo.Client {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(EnvMongoURI()))
 	if err != nil {
 		log.Fatal(err)
 	}
+	return client
+}
+
+// ConnectDB checks the database can be reached, at startup
+func ConnectDB() *mongo.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	//ping the database
-	err = client.Ping(ctx, nil)
+	err := DB.Ping(ctx, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("Connected to MongoDB")
-	return client
+	return DB
 }
 
 // getting database collections
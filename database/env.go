@@ -7,11 +7,17 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// defaultMongoURI is used when MONGOURI is not set, e.g. when running unit tests
+const defaultMongoURI = "mongodb://localhost:27017"
+
 func EnvMongoURI() string {
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Println("No .env file loaded, using the environment")
 	}
 
-	return os.Getenv("MONGOURI")
+	if uri := os.Getenv("MONGOURI"); uri != "" {
+		return uri
+	}
+	return defaultMongoURI
 }
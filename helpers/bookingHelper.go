@@ -0,0 +1,281 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"busapp/payments"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var bookingCollection *mongo.Collection = configs.GetCollection(configs.DB, "booking")
+
+// ErrNotEnoughSeats is returned when a bus cannot accommodate the requested seats
+var ErrNotEnoughSeats = errors.New("not enough seats available")
+
+// ErrBookingNotFound is returned when a booking does not exist or belongs to another user
+var ErrBookingNotFound = errors.New("booking not found")
+
+// ErrBookingNotCancellable is returned when a booking is no longer confirmed
+var ErrBookingNotCancellable = errors.New("booking cannot be cancelled")
+
+// ErrTripDeparted is returned when a booking is cancelled after its trip departed
+var ErrTripDeparted = errors.New("trip has already departed")
+
+// ReleaseBookedSeats gives the booked seats of a booking back to its bus
+func ReleaseBookedSeats(ctx context.Context, booking *models.Booking) error {
+	var ok bool
+	var err error
+	if len(booking.Seat_numbers) > 0 {
+		ok, err = updateSeats(ctx, booking.Bus_id, booking.Seat_numbers,
+			bson.M{"status": models.SeatBooked},
+			bson.M{"status": models.SeatFree},
+			bson.M{"seats_booked": -booking.Seats})
+	} else {
+		ok, err = updateSeatCounts(ctx, booking.Bus_id, bson.M{"seats_booked": bson.M{"$gte": booking.Seats}},
+			bson.M{"seats_booked": -booking.Seats})
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("seats of booking %s are not booked on the bus %s", booking.Booking_id, booking.Bus_id)
+	}
+	return nil
+}
+
+// CreateBooking starts checkout of the user's hold. The booking is stored as
+// pending together with a payment intent for the quoted price; the seats stay
+// held until the payment webhook confirms or fails the booking.
+func CreateBooking(ctx context.Context, holdID string, userID string) (*models.Booking, *payments.Intent, error) {
+	hold, err := GetHoldByHoldId(ctx, holdID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hold == nil || hold.User_id != userID || hold.Status != models.HoldActive || !time.Now().Before(hold.Expires_at) {
+		return nil, nil, ErrHoldNotActive
+	}
+
+	// A hold can only be checked out once at a time. This check spares a
+	// payment intent; the unique index on hold_id settles concurrent checkouts.
+	count, err := bookingCollection.CountDocuments(ctx, bson.M{
+		"hold_id": hold.Hold_id,
+		"status":  bson.M{"$in": bson.A{models.BookingPending, models.BookingConfirmed}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if count > 0 {
+		return nil, nil, ErrHoldNotActive
+	}
+
+	booking := models.Booking{
+		ID:           primitive.NewObjectID(),
+		Bus_id:       hold.Bus_id,
+		User_id:      userID,
+		Hold_id:      hold.Hold_id,
+		Seats:        hold.Seats,
+		Seat_numbers: hold.Seat_numbers,
+		Passengers:   hold.Passengers,
+		From:         hold.From,
+		To:           hold.To,
+		Price:        hold.Price,
+		Amount:       hold.Amount,
+		Status:       models.BookingPending,
+		History: []models.BookingEvent{
+			{Status: models.BookingPending, Amount: hold.Amount, At: time.Now()},
+		},
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	booking.Booking_id = booking.ID.Hex()
+
+	intent, err := PaymentProvider.CreateIntent(ctx, booking.Amount, PaymentCurrency(), booking.Booking_id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create payment: %v", err)
+	}
+	booking.Payment_intent_id = intent.ID
+
+	if _, err := bookingCollection.InsertOne(ctx, booking); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, nil, ErrHoldNotActive // Checked out at the same time by another request
+		}
+		return nil, nil, fmt.Errorf("failed to store booking: %v", err)
+	}
+
+	return &booking, intent, nil
+}
+
+// GetBookingByBookingId retrieves a booking by booking_id
+func GetBookingByBookingId(ctx context.Context, bookingID string) (*models.Booking, error) {
+	var booking models.Booking
+	err := bookingCollection.FindOne(ctx, bson.M{"booking_id": bookingID}).Decode(&booking)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Booking not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// CancelBooking cancels a confirmed booking of the user before the trip
+// departs. The refund is worked out from the refund policy of the route,
+// recorded in the booking history, and the seats are given back to the bus.
+func CancelBooking(ctx context.Context, bookingID string, userID string) (*models.Booking, error) {
+	booking, err := GetBookingByBookingId(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if booking == nil || booking.User_id != userID {
+		return nil, ErrBookingNotFound
+	}
+	if booking.Status != models.BookingConfirmed {
+		return nil, ErrBookingNotCancellable
+	}
+
+	bus, err := GetBusByBusId(ctx, booking.Bus_id)
+	if err != nil {
+		return nil, err
+	}
+	if bus == nil {
+		return nil, fmt.Errorf("bus %s of booking %s not found", booking.Bus_id, booking.Booking_id)
+	}
+	status := TripStatus(bus)
+	if status == models.TripDeparted || status == models.TripArrived {
+		return nil, ErrTripDeparted
+	}
+	departure, err := TripDeparture(bus)
+	if err != nil {
+		return nil, err
+	}
+	// A delayed trip can be cancelled until it actually leaves
+	departure = departure.Add(time.Duration(bus.Delay_minutes) * time.Minute)
+	now := time.Now()
+	if !now.Before(departure) {
+		return nil, ErrTripDeparted
+	}
+
+	route, err := GetRouteByRouteId(ctx, bus.Route_id)
+	if err != nil {
+		return nil, err
+	}
+	refund := RefundAmount(RouteRefundPolicy(route), booking.Amount, departure.Sub(now))
+
+	cancelled, err := cancelConfirmedBooking(ctx, booking, refund, "refund")
+	if err != nil {
+		return nil, err
+	}
+	go seatsFreed(context.Background(), cancelled.Bus_id)
+
+	return cancelled, nil
+}
+
+// cancelConfirmedBooking marks a confirmed booking cancelled with its refund,
+// gives its seats back to the bus and pays the refund back. Only one
+// cancellation can win, so the seats are released exactly once. When the
+// seats cannot be released right away the release stays pending for the
+// sweeper, see ReleasePendingCancellations.
+func cancelConfirmedBooking(ctx context.Context, booking *models.Booking, refund float64, note string) (*models.Booking, error) {
+	now := time.Now()
+	filter := bson.M{"booking_id": booking.Booking_id, "status": models.BookingConfirmed}
+	update := bson.M{
+		"$set": bson.M{
+			"status":        models.BookingCancelled,
+			"refund_amount": refund,
+			"seats_release": models.SeatsReleasePending,
+			"cancelled_at":  now,
+			"updated_at":    now,
+		},
+		"$push": bson.M{"history": models.BookingEvent{
+			Status: models.BookingCancelled,
+			Amount: refund,
+			Note:   note,
+			At:     now,
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var cancelled models.Booking
+	err := bookingCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&cancelled)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBookingNotCancellable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := releaseCancelledSeats(ctx, &cancelled); err != nil {
+		log.Println("Error releasing seats of cancelled booking", cancelled.Booking_id, err)
+	}
+
+	if err := RefundBookingPayment(ctx, &cancelled, refund); err != nil {
+		log.Println("Error refunding booking", cancelled.Booking_id, err)
+	}
+
+	return &cancelled, nil
+}
+
+// releaseCancelledSeats gives the seats of a cancelled booking back to the
+// bus if their release is still pending. The release is claimed first so
+// the seats are never released twice, and handed back to the sweeper when
+// it fails.
+func releaseCancelledSeats(ctx context.Context, booking *models.Booking) error {
+	filter := bson.M{"booking_id": booking.Booking_id, "seats_release": models.SeatsReleasePending}
+	claimed, err := bookingCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"seats_release": models.SeatsReleaseReleasing}})
+	if err != nil {
+		return err
+	}
+	if claimed.ModifiedCount == 0 {
+		return nil // Released by someone else
+	}
+
+	next := models.SeatsReleaseDone
+	releaseErr := ReleaseBookedSeats(ctx, booking)
+	if releaseErr != nil {
+		next = models.SeatsReleasePending
+	}
+	_, err = bookingCollection.UpdateOne(ctx,
+		bson.M{"booking_id": booking.Booking_id, "seats_release": models.SeatsReleaseReleasing},
+		bson.M{"$set": bson.M{"seats_release": next}},
+	)
+	if releaseErr != nil {
+		return releaseErr
+	}
+	return err
+}
+
+// ReleasePendingCancellations retries giving back the seats of cancelled
+// bookings whose release failed before
+func ReleasePendingCancellations(ctx context.Context) (int, error) {
+	var bookings []models.Booking
+	cursor, err := bookingCollection.Find(ctx, bson.M{
+		"status":        models.BookingCancelled,
+		"seats_release": models.SeatsReleasePending,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &bookings); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for i := range bookings {
+		if err := releaseCancelledSeats(ctx, &bookings[i]); err != nil {
+			log.Println("Error releasing seats of cancelled booking", bookings[i].Booking_id, err)
+			continue
+		}
+		released++
+		seatsFreed(ctx, bookings[i].Bus_id)
+	}
+	return released, nil
+}
@@ -0,0 +1,291 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidBusEdit wraps every reason an edit of a bus is rejected
+var ErrInvalidBusEdit = errors.New("invalid bus edit")
+
+// ErrBusChanged is returned when a bus changed between reading and updating it
+var ErrBusChanged = errors.New("bus was changed by someone else, try again")
+
+// ErrBusInUse is returned when deleting a bus that has or had bookings
+var ErrBusInUse = errors.New("bus has bookings")
+
+// BusEditRequest is an edit of a bus. Fields left out keep their value; the
+// fare is a pointer so a bus can be made free.
+type BusEditRequest struct {
+	Route_id       string            `json:"route_id"`
+	Date           string            `json:"date"`
+	Departure_time string            `json:"departure_time"`
+	Departure_at   time.Time         `json:"departure_at"`
+	Bus_type       string            `json:"bus_type"`
+	Fare           *float64          `json:"fare"`
+	SeatsTotal     int               `json:"seats_total"`
+	Layout         models.SeatLayout `json:"layout"`
+}
+
+// EditBus changes the departure, route, type, fare or seats of a bus. Fields
+// left empty in the request keep their value; an RFC 3339 departure_at wins
+// over a local date and departure_time. Seats that are
+// booked or held keep their state, so the bus can never shrink below them.
+func EditBus(ctx context.Context, bus *models.Bus, request BusEditRequest) (*models.Bus, error) {
+	if !TripBookable(bus) {
+		return nil, fmt.Errorf("%w: %s trips cannot be edited", ErrInvalidBusEdit, TripStatus(bus))
+	}
+	occupied := bus.SeatsBooked + bus.SeatsHeld
+
+	routeID := bus.Route_id
+	if request.Route_id != "" && request.Route_id != bus.Route_id {
+		if occupied > 0 {
+			return nil, fmt.Errorf("%w: the route of a trip with bookings cannot be changed", ErrInvalidBusEdit)
+		}
+		routeID = request.Route_id
+	}
+	route, err := GetRouteByRouteId(ctx, routeID)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil {
+		return nil, fmt.Errorf("%w: route %s not found", ErrInvalidBusEdit, routeID)
+	}
+
+	edited := models.Bus{
+		Schedule_id:    bus.Schedule_id,
+		Date:           bus.Date,
+		Departure_time: bus.Departure_time,
+		Bus_type:       bus.Bus_type,
+		Fare:           bus.Fare,
+		SeatsTotal:     bus.SeatsTotal,
+		Layout:         bus.Layout,
+	}
+	if !request.Departure_at.IsZero() {
+		edited.Departure_at = request.Departure_at
+	}
+	if request.Date != "" {
+		edited.Date = request.Date
+	}
+	if request.Departure_time != "" {
+		edited.Departure_time = request.Departure_time
+	}
+	if request.Bus_type != "" {
+		edited.Bus_type = request.Bus_type
+	}
+	if request.Fare != nil {
+		edited.Fare = *request.Fare
+	}
+	seatMapChanged := true
+	switch {
+	case request.Layout.Rows != 0 || request.Layout.Columns != 0:
+		edited.Layout = request.Layout
+	case request.SeatsTotal != 0:
+		edited.Layout = models.SeatLayout{}
+		edited.SeatsTotal = request.SeatsTotal
+	default:
+		seatMapChanged = false
+	}
+
+	candidate, err := NewBus(route, edited)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBusEdit, err)
+	}
+
+	seats, layout, seatsTotal := bus.Seats, bus.Layout, bus.SeatsTotal
+	if seatMapChanged {
+		if candidate.SeatsTotal < occupied {
+			return nil, fmt.Errorf("%w: the bus cannot have fewer than its %d booked or held seats", ErrInvalidBusEdit, occupied)
+		}
+		layout, seatsTotal = candidate.Layout, candidate.SeatsTotal
+		seats = nil
+		if len(bus.Seats) > 0 {
+			seats, err = carrySeatStates(bus.Seats, candidate.Seats)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Only apply the edit if nobody booked, held or edited seats in the meantime
+	filter := bson.M{"bus_id": bus.Bus_id, "updated_at": bus.Updated_at}
+	update := bson.M{
+		"$set": bson.M{
+			"route_id":       route.Route_id,
+			"origin":         route.Origin,
+			"destination":    route.Destination,
+			"date":           candidate.Date,
+			"departure_time": candidate.Departure_time,
+			"departure_at":   candidate.Departure_at,
+			"arrival_at":     candidate.Arrival_at,
+			"bus_type":       candidate.Bus_type,
+			"fare":           candidate.Fare,
+			"seats_total":    seatsTotal,
+			"layout":         layout,
+			"seats":          seats,
+			"updated_at":     time.Now(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Bus
+	err = busCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBusChanged
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("%w: another trip on the route departs at the same time", ErrInvalidBusEdit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	seatsChanged(updated.Bus_id)
+	return &updated, nil
+}
+
+// carrySeatStates copies the state of every booked or held seat onto the new
+// seat map, failing when such a seat does not exist on it anymore
+func carrySeatStates(oldSeats []models.Seat, newSeats []models.Seat) ([]models.Seat, error) {
+	index := make(map[string]int, len(newSeats))
+	for i, seat := range newSeats {
+		index[seat.Seat_no] = i
+	}
+
+	for _, seat := range oldSeats {
+		if seat.Status == models.SeatFree {
+			continue
+		}
+		i, exists := index[seat.Seat_no]
+		if !exists {
+			return nil, fmt.Errorf("%w: seat %s is %s and must stay on the bus", ErrInvalidBusEdit, seat.Seat_no, seat.Status)
+		}
+		newSeats[i].Status = seat.Status
+		newSeats[i].Hold_id = seat.Hold_id
+	}
+	return newSeats, nil
+}
+
+// CancelTrip cancels a bus trip that has not departed yet and settles it, see
+// settleCancelledTrip. Cancelling an already cancelled trip settles it again,
+// so a cancellation interrupted halfway can be retried. It returns the number
+// of refunded bookings.
+func CancelTrip(ctx context.Context, busID string, event models.TripStatusEvent) (int, error) {
+	event.Status = models.TripCancelled
+	bus, err := setTripStatus(ctx, busID, event)
+	if err == ErrInvalidTransition {
+		bus, err = GetBusByBusId(ctx, busID)
+		if err == nil && bus != nil && TripStatus(bus) != models.TripCancelled {
+			return 0, ErrInvalidTransition
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if bus == nil {
+		return 0, fmt.Errorf("bus %s not found", busID)
+	}
+
+	return settleCancelledTrip(ctx, bus)
+}
+
+// settleCancelledTrip releases the open holds of a cancelled trip, fails its
+// pending checkouts, and cancels every confirmed booking with a full refund;
+// the customers of failed and cancelled bookings get an email. Only what is
+// still open is touched, so it can run again after a failure.
+func settleCancelledTrip(ctx context.Context, bus *models.Bus) (int, error) {
+	busID := bus.Bus_id
+
+	// Release the holds of customers still picking or paying for seats
+	for {
+		hold, err := claimHold(ctx, bson.M{"bus_id": busID}, models.HoldReleased)
+		if err == ErrHoldNotActive {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err := releaseClaimedHold(ctx, hold); err != nil {
+			return 0, err
+		}
+	}
+
+	var bookings []models.Booking
+	cursor, err := bookingCollection.Find(ctx, bson.M{
+		"bus_id": busID,
+		"status": bson.M{"$in": bson.A{models.BookingPending, models.BookingConfirmed}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &bookings); err != nil {
+		return 0, err
+	}
+
+	refunded := 0
+	for i := range bookings {
+		booking := &bookings[i]
+		if booking.Status == models.BookingPending {
+			failed, err := finishPendingBooking(ctx, booking, models.BookingFailed, "trip cancelled")
+			if err == mongo.ErrNoDocuments {
+				continue // Paid or failed in the meantime
+			}
+			if err != nil {
+				return refunded, err
+			}
+			notifyTripCancelled(ctx, bus, failed)
+			continue
+		}
+
+		cancelled, err := cancelConfirmedBooking(ctx, booking, booking.Amount, "trip cancelled")
+		if err == ErrBookingNotCancellable {
+			continue // Cancelled by the customer in the meantime
+		}
+		if err != nil {
+			return refunded, err
+		}
+		refunded++
+		notifyTripCancelled(ctx, bus, cancelled)
+	}
+
+	return refunded, nil
+}
+
+// notifyTripCancelled emails the customer of a booking on a cancelled trip
+func notifyTripCancelled(ctx context.Context, bus *models.Bus, booking *models.Booking) {
+	user, err := GetUserByUid(ctx, booking.User_id)
+	if err != nil || user == nil {
+		log.Println("Error finding the customer of booking", booking.Booking_id, err)
+		return
+	}
+	if err := SendTripCancelledEmail(user.Email, bus, booking); err != nil {
+		log.Println("Error sending trip cancellation email to", user.Email, err)
+	}
+}
+
+// DeleteBus deletes a bus that never had any bookings or held seats
+func DeleteBus(ctx context.Context, busID string) error {
+	count, err := bookingCollection.CountDocuments(ctx, bson.M{"bus_id": busID})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrBusInUse
+	}
+
+	result, err := busCollection.DeleteOne(ctx, bson.M{"bus_id": busID, "seats_booked": 0, "seats_held": 0})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrBusInUse
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var busCollection *mongo.Collection = configs.GetCollection(configs.DB, "bus")
+
+// GetBusByBusId retrieves a bus by bus_id
+func GetBusByBusId(ctx context.Context, busID string) (*models.Bus, error) {
+	var bus models.Bus
+	err := busCollection.FindOne(ctx, bson.M{"bus_id": busID}).Decode(&bus)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Bus not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &bus, nil
+}
+
+// DefaultLayout returns a plain 2+2 seater layout large enough for the given number of seats
+func DefaultLayout(seatsTotal int) models.SeatLayout {
+	return models.SeatLayout{
+		Type:        models.LayoutSeater,
+		Rows:        (seatsTotal + 3) / 4,
+		Columns:     4,
+		Aisle_after: 2,
+		Decks:       1,
+	}
+}
+
+// ValidateLayout checks that a seat layout can be turned into a seat map
+func ValidateLayout(layout models.SeatLayout) error {
+	if layout.Type != models.LayoutSeater && layout.Type != models.LayoutSleeper {
+		return fmt.Errorf("layout type must be %q or %q", models.LayoutSeater, models.LayoutSleeper)
+	}
+	if layout.Rows <= 0 || layout.Columns <= 0 {
+		return fmt.Errorf("layout rows and columns must be positive")
+	}
+	if layout.Aisle_after < 0 || layout.Aisle_after >= layout.Columns {
+		return fmt.Errorf("layout aisle_after must be between 0 and %d", layout.Columns-1)
+	}
+	if layout.Decks != 1 && layout.Decks != 2 {
+		return fmt.Errorf("layout decks must be 1 or 2")
+	}
+	if layout.Decks == 2 && layout.Type != models.LayoutSleeper {
+		return fmt.Errorf("only sleeper layouts can have an upper deck")
+	}
+	return nil
+}
+
+// BuildSeatMap numbers every seat of a layout. Seats are numbered by row and
+// column letter ("1A", "1B", ...); on double deck sleepers the lower and upper
+// berths are prefixed with "L" and "U". When limit is positive only the first
+// limit seats of the layout are used.
+func BuildSeatMap(layout models.SeatLayout, limit int) []models.Seat {
+	var seats []models.Seat
+	decks := []string{""}
+	if layout.Decks == 2 {
+		decks = []string{"lower", "upper"}
+	}
+
+	for _, deck := range decks {
+		prefix := ""
+		if deck != "" {
+			prefix = strings.ToUpper(deck[:1])
+		}
+		for row := 1; row <= layout.Rows; row++ {
+			for column := 1; column <= layout.Columns; column++ {
+				if limit > 0 && len(seats) == limit {
+					return seats
+				}
+				seats = append(seats, models.Seat{
+					Seat_no: fmt.Sprintf("%s%d%c", prefix, row, 'A'+column-1),
+					Deck:    deck,
+					Row:     row,
+					Column:  column,
+					Status:  models.SeatFree,
+				})
+			}
+		}
+	}
+	return seats
+}
+
+// PickFreeSeats returns the numbers of the first n free seats on a bus
+func PickFreeSeats(bus *models.Bus, n int) ([]string, error) {
+	var seatNumbers []string
+	for _, seat := range bus.Seats {
+		if len(seatNumbers) == n {
+			break
+		}
+		if seat.Status == models.SeatFree {
+			seatNumbers = append(seatNumbers, seat.Seat_no)
+		}
+	}
+	if len(seatNumbers) < n {
+		return nil, ErrNotEnoughSeats
+	}
+	return seatNumbers, nil
+}
+
+// ValidateSeatNumbers checks that every seat number exists on the bus and is requested only once
+func ValidateSeatNumbers(bus *models.Bus, seatNumbers []string) error {
+	known := make(map[string]bool, len(bus.Seats))
+	for _, seat := range bus.Seats {
+		known[seat.Seat_no] = true
+	}
+
+	requested := make(map[string]bool, len(seatNumbers))
+	for _, seatNo := range seatNumbers {
+		if !known[seatNo] {
+			return fmt.Errorf("seat %s does not exist on this bus", seatNo)
+		}
+		if requested[seatNo] {
+			return fmt.Errorf("seat %s is requested more than once", seatNo)
+		}
+		requested[seatNo] = true
+	}
+	return nil
+}
+
+// updateSeats atomically changes the given seats of a bus. The update only
+// matches when every seat also matches the extra conditions in match (such as
+// its current status), so either all seats change or none of them do. The
+// fields in set are applied to each seat and inc to the bus counters.
+func updateSeats(ctx context.Context, busID string, seatNumbers []string, match bson.M, set bson.M, inc bson.M) (bool, error) {
+	conditions := bson.A{}
+	for _, seatNo := range seatNumbers {
+		condition := bson.M{"seat_no": seatNo}
+		for key, value := range match {
+			condition[key] = value
+		}
+		conditions = append(conditions, bson.M{"$elemMatch": condition})
+	}
+
+	seatSet := bson.M{"updated_at": time.Now()}
+	for key, value := range set {
+		seatSet["seats.$[s]."+key] = value
+	}
+
+	filter := bson.M{"bus_id": busID, "seats": bson.M{"$all": conditions}}
+	update := bson.M{"$set": seatSet, "$inc": inc}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"s.seat_no": bson.M{"$in": seatNumbers}}},
+	})
+
+	result, err := busCollection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount > 0 {
+		seatsChanged(busID)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// updateSeatCounts atomically changes the seat counters of a bus without a
+// seat map, but only when the bus also matches the extra conditions in filter
+func updateSeatCounts(ctx context.Context, busID string, filter bson.M, inc bson.M) (bool, error) {
+	filter["bus_id"] = busID
+	update := bson.M{
+		"$inc": inc,
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := busCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount > 0 {
+		seatsChanged(busID)
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// TripDeparture returns the instant a bus trip departs from its first stop
+func TripDeparture(bus *models.Bus) (time.Time, error) {
+	if !bus.Departure_at.IsZero() {
+		return bus.Departure_at, nil
+	}
+
+	// Trips created before trips had a departure instant are in the server's time zone
+	departure, err := time.ParseInLocation(DateFormat+" "+ClockFormat, bus.Date+" "+bus.Departure_time, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bus %s has an invalid date or departure time: %v", bus.Bus_id, err)
+	}
+	return departure, nil
+}
+
+// TripLocalDeparture returns the departure of a bus trip in the time zone of
+// the first stop of its route, where its weekday and date are counted
+func TripLocalDeparture(ctx context.Context, bus *models.Bus) (time.Time, error) {
+	route, err := GetRouteByRouteId(ctx, bus.Route_id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return localDeparture(bus, route)
+}
+
+// localDeparture returns the departure of a bus trip on a route in the time
+// zone of its first stop. The route may be nil.
+func localDeparture(bus *models.Bus, route *models.Route) (time.Time, error) {
+	departure, err := TripDeparture(bus)
+	if err != nil {
+		return time.Time{}, err
+	}
+	location, err := RouteLocation(route)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return departure.In(location), nil
+}
+
+// tripTimes works out when a trip on a route departs and arrives, either from
+// the departure instant or from the local date and departure time at the
+// first stop, and returns the local date and time of the departure as well
+func tripTimes(route *models.Route, request models.Bus) (time.Time, time.Time, error) {
+	departure := request.Departure_at
+	if departure.IsZero() {
+		var err error
+		departure, err = LocalDeparture(route, request.Date, request.Departure_time)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	location, err := RouteLocation(route)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	departure = departure.Truncate(time.Minute).In(location)
+
+	arrival := departure
+	if len(route.Stops) > 0 {
+		arrival = departure.Add(time.Duration(route.Stops[len(route.Stops)-1].Arrival_offset) * time.Minute)
+	}
+	return departure, arrival, nil
+}
+
+// NewBus builds a new bus trip on a route from the requested departure, fare
+// and seat layout. The departure is either an RFC 3339 departure_at or a
+// local date and departure_time at the first stop of the route. When no
+// layout is given a plain seater with SeatsTotal seats is used.
+func NewBus(route *models.Route, request models.Bus) (models.Bus, error) {
+	layout := request.Layout
+	limit := 0
+	if layout.Rows == 0 && layout.Columns == 0 {
+		layout = DefaultLayout(request.SeatsTotal)
+		limit = request.SeatsTotal
+	}
+	if layout.Decks == 0 {
+		layout.Decks = 1
+	}
+	if err := ValidateLayout(layout); err != nil {
+		return models.Bus{}, err
+	}
+	seats := BuildSeatMap(layout, limit)
+
+	// Check if the required fields are present
+	if len(seats) == 0 || len(seats) > 45 {
+		return models.Bus{}, fmt.Errorf("total seats (at most 45) are required")
+	}
+	if request.Departure_at.IsZero() && (request.Date == "" || request.Departure_time == "") {
+		return models.Bus{}, fmt.Errorf("departure_at or date and departure_time are required")
+	}
+	departure, arrival, err := tripTimes(route, request)
+	if err != nil {
+		return models.Bus{}, err
+	}
+	if request.Fare < 0 {
+		return models.Bus{}, fmt.Errorf("fare cannot be negative")
+	}
+
+	bus := models.Bus{
+		ID:             primitive.NewObjectID(),
+		Route_id:       route.Route_id,
+		Schedule_id:    request.Schedule_id,
+		Origin:         route.Origin,
+		Destination:    route.Destination,
+		Date:           departure.Format(DateFormat),
+		Departure_time: departure.Format(ClockFormat),
+		Departure_at:   departure.UTC(),
+		Arrival_at:     arrival.UTC(),
+		Bus_type:       request.Bus_type,
+		Status:         models.TripScheduled,
+		Fare:           request.Fare,
+		SeatsTotal:     len(seats),
+		Layout:         layout,
+		Seats:          seats,
+		Created_at:     time.Now(),
+		Updated_at:     time.Now(),
+	}
+	bus.Bus_id = bus.ID.Hex()
+	return bus, nil
+}
+
+// BackfillTripTimes sets the departure and arrival instants of trips created
+// before trips had them, reading their date and departure time in the time
+// zone of the first stop of their route
+func BackfillTripTimes(ctx context.Context) (int, error) {
+	var buses []models.Bus
+	cursor, err := busCollection.Find(ctx, bson.M{"departure_at": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &buses); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, bus := range buses {
+		route, err := GetRouteByRouteId(ctx, bus.Route_id)
+		if err != nil {
+			return updated, err
+		}
+		if route == nil {
+			route = &models.Route{}
+		}
+		departure, arrival, err := tripTimes(route, bus)
+		if err != nil {
+			log.Println("Skipping bus", bus.Bus_id, err)
+			continue
+		}
+		_, err = busCollection.UpdateOne(ctx, bson.M{"bus_id": bus.Bus_id}, bson.M{
+			"$set": bson.M{"departure_at": departure.UTC(), "arrival_at": arrival.UTC()},
+		})
+		if err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
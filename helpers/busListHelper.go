@@ -0,0 +1,251 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Sort orders of the admin bus listing
+const (
+	BusSortDeparture  = "departure"
+	BusSortFare       = "fare"
+	BusSortLoadFactor = "load_factor"
+)
+
+// DefaultBusListLimit and MaxBusListLimit bound the page size of the bus listing
+const (
+	DefaultBusListLimit = 20
+	MaxBusListLimit     = 100
+)
+
+// ErrInvalidCursor is returned when a page cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// busSortFields maps the sort orders to the field they sort on in the pipeline
+var busSortFields = map[string]string{
+	BusSortDeparture:  "departure_at",
+	BusSortFare:       "fare",
+	BusSortLoadFactor: "load_factor",
+}
+
+// BusListQuery holds the filters, sort order and page of the admin bus
+// listing. Occupancy bounds are nil when not filtered on.
+type BusListQuery struct {
+	Date_from     string
+	Date_to       string
+	Route_id      string
+	Status        string
+	Min_occupancy *float64
+	Max_occupancy *float64
+	Sort          string
+	Descending    bool
+	Limit         int
+	Cursor        string
+}
+
+// busCursor is the position after the last row of a page: the sort value of
+// that row and its bus_id to break ties. Departures are unix milliseconds.
+type busCursor struct {
+	Value  interface{} `json:"v"`
+	Bus_id string      `json:"id"`
+}
+
+func encodeBusCursor(cursor busCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeBusCursor(encoded string) (*busCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor busCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Bus_id == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// ListBuses returns one page of buses matching the query together with the
+// cursor of the next page, which is empty on the last page. The load factor
+// is the percentage of booked seats and the revenue what confirmed and
+// cancelled bookings paid after refunds.
+func ListBuses(ctx context.Context, query BusListQuery) ([]models.BusSummary, string, error) {
+	sortField, exists := busSortFields[query.Sort]
+	if !exists {
+		sortField = busSortFields[BusSortDeparture]
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultBusListLimit
+	}
+	if query.Limit > MaxBusListLimit {
+		query.Limit = MaxBusListLimit
+	}
+
+	match := bson.M{}
+	date := bson.M{}
+	if query.Date_from != "" {
+		date["$gte"] = query.Date_from
+	}
+	if query.Date_to != "" {
+		date["$lte"] = query.Date_to
+	}
+	if len(date) > 0 {
+		match["date"] = date
+	}
+	if query.Route_id != "" {
+		match["route_id"] = query.Route_id
+	}
+	if query.Status == models.TripScheduled {
+		// Buses created before trips had a status are scheduled too
+		match["status"] = bson.M{"$in": bson.A{models.TripScheduled, nil}}
+	} else if query.Status != "" {
+		match["status"] = query.Status
+	}
+
+	occupancy := bson.M{}
+	if query.Min_occupancy != nil {
+		occupancy["$gte"] = *query.Min_occupancy
+	}
+	if query.Max_occupancy != nil {
+		occupancy["$lte"] = *query.Max_occupancy
+	}
+
+	direction := 1
+	after := "$gt"
+	if query.Descending {
+		direction = -1
+		after = "$lt"
+	}
+
+	// Specify the fields you want to retrieve
+	projection := bson.M{
+		"bus_id": 1, "route_id": 1, "origin": 1, "destination": 1, "date": 1,
+		"departure_time": 1, "departure_at": 1, "arrival_at": 1, "bus_type": 1, "status": 1, "fare": 1,
+		"seats_total": 1, "seats_booked": 1, "seats_held": 1,
+		"load_factor": 1, "created_at": 1,
+	}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$project": projection},
+		bson.M{"$addFields": bson.M{
+			"load_factor": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$seats_total", 0}},
+				bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{bson.M{"$divide": bson.A{"$seats_booked", "$seats_total"}}, 100}}, 2}},
+				0,
+			}},
+		}},
+	}
+	if len(occupancy) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"load_factor": occupancy}})
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeBusCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		value := cursor.Value
+		if sortField == "departure_at" {
+			// Trips sort by the instant they depart, not their local date and time
+			millis, isNumber := cursor.Value.(float64)
+			if !isNumber {
+				return nil, "", ErrInvalidCursor
+			}
+			value = time.UnixMilli(int64(millis)).UTC()
+		}
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"$or": bson.A{
+			bson.M{sortField: bson.M{after: value}},
+			bson.M{sortField: value, "bus_id": bson.M{after: cursor.Bus_id}},
+		}}})
+	}
+	pipeline = append(pipeline,
+		bson.M{"$sort": bson.D{{Key: sortField, Value: direction}, {Key: "bus_id", Value: direction}}},
+		bson.M{"$limit": query.Limit + 1},
+	)
+
+	var rows []models.BusSummary
+	cursor, err := busCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		last := rows[len(rows)-1]
+		var value interface{}
+		switch sortField {
+		case "fare":
+			value = last.Fare
+		case "load_factor":
+			value = last.Load_factor
+		default:
+			value = last.Departure_at.UnixMilli()
+		}
+		next = encodeBusCursor(busCursor{Value: value, Bus_id: last.Bus_id})
+	}
+
+	buses := make([]models.BusSummary, 0, len(rows))
+	busIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		buses = append(buses, row)
+		busIDs = append(busIDs, row.Bus_id)
+	}
+
+	revenue, err := busRevenue(ctx, busIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range buses {
+		buses[i].Revenue = revenue[buses[i].Bus_id]
+	}
+
+	return buses, next, nil
+}
+
+// busRevenue sums what the bookings of each bus paid, less their refunds
+func busRevenue(ctx context.Context, busIDs []string) (map[string]float64, error) {
+	revenue := make(map[string]float64, len(busIDs))
+	if len(busIDs) == 0 {
+		return revenue, nil
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"bus_id": bson.M{"$in": busIDs},
+			"status": bson.M{"$in": bson.A{models.BookingConfirmed, models.BookingCancelled}},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":     "$bus_id",
+			"revenue": bson.M{"$sum": bson.M{"$subtract": bson.A{"$amount", bson.M{"$ifNull": bson.A{"$refund_amount", 0}}}}},
+		}},
+	}
+	cursor, err := bookingCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Bus_id  string  `bson:"_id"`
+		Revenue float64 `bson:"revenue"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	for _, total := range totals {
+		revenue[total.Bus_id] = roundCents(total.Revenue)
+	}
+	return revenue, nil
+}
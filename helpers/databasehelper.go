@@ -69,6 +69,7 @@ func UpdateUserDetailsByUid(ctx context.Context, updateUserDetailsRequest models
 	if updateUserDetailsRequest.Phone == "" {
 		updateUserDetailsRequest.Phone = existingUser.Phone
 	}
+	passwordChanged := updateUserDetailsRequest.Password != ""
 	if updateUserDetailsRequest.Password == "" {
 		updateUserDetailsRequest.Password = existingUser.Password
 	}
@@ -128,6 +129,10 @@ func UpdateUserDetailsByUid(ctx context.Context, updateUserDetailsRequest models
 		return fmt.Errorf("No user found with the user_id: %s", user_id)
 	}
 
+	// Tokens issued with the old password stop working
+	if passwordChanged {
+		return RevokeAllUserTokens(ctx, existingUser.UserID)
+	}
 	return nil
 }
 
@@ -185,7 +190,8 @@ func UpdateUserPasswordByEmail(ctx context.Context, email string, newPassword st
 		return fmt.Errorf("no user found with the email: %s", email)
 	}
 
-	return nil
+	// Tokens issued with the old password stop working
+	return revokeUserTokensByEmail(ctx, email)
 }
 
 // GetUserByResetToken retrieves a user by their reset token from the database
@@ -205,7 +211,7 @@ func GetAllCustomersFromDatabase(ctx context.Context) ([]models.LimitedUserDetai
 	var users []models.LimitedUserDetails
 	// Specify the fields you want to retrieve
 	projection := bson.M{"username": 1, "email": 1, "phone": 1, "created_at": 1}
-	cursor, err := userCollection.Find(ctx, bson.M{"role": "customer"}, options.Find().SetProjection(projection))
+	cursor, err := userCollection.Find(ctx, bson.M{"role": bson.M{"$in": bson.A{models.RoleCustomer, nil, ""}}}, options.Find().SetProjection(projection))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,372 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var holdCollection *mongo.Collection = configs.GetCollection(configs.DB, "hold")
+
+// ErrTripNotBookable is returned when seats are requested on a cancelled or departed trip
+var ErrTripNotBookable = errors.New("trip is not open for booking")
+
+// ErrHoldNotActive is returned when a hold has expired, was released or was already turned into a booking
+var ErrHoldNotActive = errors.New("hold is not active")
+
+// HoldDuration returns how long seats stay held before checkout, configured
+// in minutes through HOLD_MINUTES (10 minutes by default)
+func HoldDuration() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("HOLD_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 10
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// holdSeats atomically marks free seats as held by the hold. Buses without a
+// seat map only count the held seats, as long as there is room for them.
+func holdSeats(ctx context.Context, hold *models.Hold) error {
+	var ok bool
+	var err error
+	if len(hold.Seat_numbers) > 0 {
+		ok, err = updateSeats(ctx, hold.Bus_id, hold.Seat_numbers,
+			bson.M{"status": models.SeatFree},
+			bson.M{"status": models.SeatHeld, "hold_id": hold.Hold_id},
+			bson.M{"seats_held": hold.Seats})
+	} else {
+		ok, err = updateSeatCounts(ctx, hold.Bus_id, bson.M{
+			"$expr": bson.M{
+				"$lte": bson.A{bson.M{"$add": bson.A{"$seats_booked", "$seats_held", hold.Seats}}, "$seats_total"},
+			},
+		}, bson.M{"seats_held": hold.Seats})
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotEnoughSeats
+	}
+	return nil
+}
+
+// releaseHeldSeats gives the seats of a hold back to the bus
+func releaseHeldSeats(ctx context.Context, hold *models.Hold) error {
+	var ok bool
+	var err error
+	if len(hold.Seat_numbers) > 0 {
+		ok, err = updateSeats(ctx, hold.Bus_id, hold.Seat_numbers,
+			bson.M{"status": models.SeatHeld, "hold_id": hold.Hold_id},
+			bson.M{"status": models.SeatFree, "hold_id": ""},
+			bson.M{"seats_held": -hold.Seats})
+	} else {
+		ok, err = updateSeatCounts(ctx, hold.Bus_id, bson.M{"seats_held": bson.M{"$gte": hold.Seats}},
+			bson.M{"seats_held": -hold.Seats})
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("seats of hold %s are no longer held on the bus %s", hold.Hold_id, hold.Bus_id)
+	}
+	return nil
+}
+
+// bookHeldSeats turns the held seats of a hold into booked seats
+func bookHeldSeats(ctx context.Context, hold *models.Hold) error {
+	var ok bool
+	var err error
+	if len(hold.Seat_numbers) > 0 {
+		ok, err = updateSeats(ctx, hold.Bus_id, hold.Seat_numbers,
+			bson.M{"status": models.SeatHeld, "hold_id": hold.Hold_id},
+			bson.M{"status": models.SeatBooked, "hold_id": ""},
+			bson.M{"seats_held": -hold.Seats, "seats_booked": hold.Seats})
+	} else {
+		ok, err = updateSeatCounts(ctx, hold.Bus_id, bson.M{"seats_held": bson.M{"$gte": hold.Seats}},
+			bson.M{"seats_held": -hold.Seats, "seats_booked": hold.Seats})
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("seats of hold %s are no longer held on the bus %s", hold.Hold_id, hold.Bus_id)
+	}
+	return nil
+}
+
+// HoldRequest describes the seats a customer wants to hold. When passengers
+// are given there is one seat per passenger, and seat numbers come from the
+// passengers instead of Seat_numbers.
+type HoldRequest struct {
+	Seats        int
+	Seat_numbers []string
+	From         string
+	To           string
+	Passengers   []models.Passenger
+}
+
+// CreateHold holds seats on a bus for the user until the hold expires. Buses
+// with a seat map hold the given seat numbers (or the first free seats when
+// none are given); older buses without a seat map only hold a seat count. All
+// seats are held or none of them. The seats are priced between the from and
+// to cities (the whole route when empty), each passenger individually, and the
+// quote is kept on the hold so checkout charges that price.
+func CreateHold(ctx context.Context, bus *models.Bus, userID string, request HoldRequest) (*models.Hold, error) {
+	if !TripBookable(bus) {
+		return nil, ErrTripNotBookable
+	}
+
+	from, to, err := ResolveSegment(ctx, bus, request.From, request.To)
+	if err != nil {
+		return nil, err
+	}
+
+	seats, seatNumbers := request.Seats, request.Seat_numbers
+	passengers := append([]models.Passenger(nil), request.Passengers...)
+	if len(passengers) > 0 {
+		seats, seatNumbers = len(passengers), PassengerSeatNumbers(passengers)
+	}
+
+	if len(bus.Seats) > 0 {
+		if len(seatNumbers) == 0 {
+			seatNumbers, err = PickFreeSeats(bus, seats)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err = ValidateSeatNumbers(bus, seatNumbers); err != nil {
+			return nil, err
+		}
+		seats = len(seatNumbers)
+	} else {
+		seatNumbers = nil
+	}
+
+	// Passengers without a seat of their choice get the picked seats in order
+	for i := range passengers {
+		if i < len(seatNumbers) {
+			passengers[i].Seat_no = seatNumbers[i]
+		}
+	}
+
+	rules, err := LoadPricingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	price, err := QuotePrice(ctx, rules, bus, from, to, seats, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(passengers) > 0 {
+		PricePassengers(&price, passengers)
+	}
+
+	hold := models.Hold{
+		ID:           primitive.NewObjectID(),
+		Bus_id:       bus.Bus_id,
+		User_id:      userID,
+		Seats:        seats,
+		Seat_numbers: seatNumbers,
+		Passengers:   passengers,
+		From:         from,
+		To:           to,
+		Price:        price,
+		Amount:       price.Total,
+		Status:       models.HoldActive,
+		Expires_at:   time.Now().Add(HoldDuration()),
+		Created_at:   time.Now(),
+		Updated_at:   time.Now(),
+	}
+	hold.Hold_id = hold.ID.Hex()
+
+	if err := holdSeats(ctx, &hold); err != nil {
+		return nil, err
+	}
+
+	if _, err := holdCollection.InsertOne(ctx, hold); err != nil {
+		if releaseErr := releaseHeldSeats(ctx, &hold); releaseErr != nil {
+			return nil, fmt.Errorf("failed to store hold: %v (releasing seats also failed: %v)", err, releaseErr)
+		}
+		return nil, fmt.Errorf("failed to store hold: %v", err)
+	}
+
+	return &hold, nil
+}
+
+// GetHoldByHoldId retrieves a hold by hold_id
+func GetHoldByHoldId(ctx context.Context, holdID string) (*models.Hold, error) {
+	var hold models.Hold
+	err := holdCollection.FindOne(ctx, bson.M{"hold_id": holdID}).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Hold not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// claimHold atomically moves an active hold to a new status. Whoever claims
+// the hold first (checkout, release or the sweeper) owns its seats, so the
+// seats of a hold are never both booked and released. Holds claimed for
+// anything but checkout are left with their seat release pending.
+func claimHold(ctx context.Context, filter bson.M, status string) (*models.Hold, error) {
+	filter["status"] = models.HoldActive
+	set := bson.M{"status": status, "updated_at": time.Now()}
+	if status != models.HoldConverted {
+		set["seats_release"] = models.SeatsReleasePending
+	}
+	update := bson.M{"$set": set}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var hold models.Hold
+	err := holdCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHoldNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// releaseClaimedHold gives the seats of a claimed hold back to the bus if
+// their release is still pending. Like the seats of cancelled bookings, the
+// release is claimed first and handed back to the sweeper when it fails.
+func releaseClaimedHold(ctx context.Context, hold *models.Hold) error {
+	filter := bson.M{"hold_id": hold.Hold_id, "seats_release": models.SeatsReleasePending}
+	claimed, err := holdCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"seats_release": models.SeatsReleaseReleasing}})
+	if err != nil {
+		return err
+	}
+	if claimed.ModifiedCount == 0 {
+		return nil // Released by someone else
+	}
+
+	next := models.SeatsReleaseDone
+	releaseErr := releaseHeldSeats(ctx, hold)
+	if releaseErr != nil {
+		next = models.SeatsReleasePending
+	}
+	_, err = holdCollection.UpdateOne(ctx,
+		bson.M{"hold_id": hold.Hold_id, "seats_release": models.SeatsReleaseReleasing},
+		bson.M{"$set": bson.M{"seats_release": next}},
+	)
+	if releaseErr != nil {
+		return releaseErr
+	}
+	return err
+}
+
+// ConvertHold books the seats of an unexpired hold owned by the user
+func ConvertHold(ctx context.Context, holdID string, userID string) (*models.Hold, error) {
+	hold, err := claimHold(ctx, bson.M{
+		"hold_id":    holdID,
+		"user_id":    userID,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, models.HoldConverted)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bookHeldSeats(ctx, hold); err != nil {
+		// The hold is already claimed, so nobody else will give its seats back.
+		// It is released instead, which also tells a repeated webhook that its
+		// booking is no longer being confirmed.
+		update := bson.M{"$set": bson.M{
+			"status":        models.HoldReleased,
+			"seats_release": models.SeatsReleasePending,
+			"updated_at":    time.Now(),
+		}}
+		if _, updateErr := holdCollection.UpdateOne(ctx, bson.M{"hold_id": hold.Hold_id}, update); updateErr != nil {
+			return nil, fmt.Errorf("failed to book held seats: %v (updating the hold also failed: %v)", err, updateErr)
+		}
+		if releaseErr := releaseClaimedHold(ctx, hold); releaseErr != nil {
+			return nil, fmt.Errorf("failed to book held seats: %v (releasing seats also failed: %v)", err, releaseErr)
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ReleaseHold gives the seats of an active hold owned by the user back to the bus
+func ReleaseHold(ctx context.Context, holdID string, userID string) error {
+	hold, err := claimHold(ctx, bson.M{"hold_id": holdID, "user_id": userID}, models.HoldReleased)
+	if err != nil {
+		return err
+	}
+	if err := releaseClaimedHold(ctx, hold); err != nil {
+		return err
+	}
+	go seatsFreed(context.Background(), hold.Bus_id)
+
+	return nil
+}
+
+// SweepExpiredHolds releases the seats of every hold that expired before
+// checkout, and retries the holds whose seats could not be released before.
+// A failed release is logged and left pending for the next sweep.
+func SweepExpiredHolds(ctx context.Context) (int, error) {
+	for {
+		_, err := claimHold(ctx, bson.M{"expires_at": bson.M{"$lte": time.Now()}}, models.HoldExpired)
+		if err == ErrHoldNotActive {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var holds []models.Hold
+	cursor, err := holdCollection.Find(ctx, bson.M{"seats_release": models.SeatsReleasePending})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &holds); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for i := range holds {
+		if err := releaseClaimedHold(ctx, &holds[i]); err != nil {
+			log.Println("Error releasing seats of hold", holds[i].Hold_id, err)
+			continue
+		}
+		released++
+		seatsFreed(ctx, holds[i].Bus_id)
+	}
+	return released, nil
+}
+
+// StartHoldSweeper releases expired holds, and the seats of cancelled
+// bookings that could not be released before, in the background at every interval
+func StartHoldSweeper(interval time.Duration) {
+	go func() {
+		for {
+			released, err := SweepExpiredHolds(context.Background())
+			if err != nil {
+				log.Println("Error releasing expired holds:", err)
+			} else if released > 0 {
+				log.Printf("Released %d expired holds\n", released)
+			}
+			cancelled, err := ReleasePendingCancellations(context.Background())
+			if err != nil {
+				log.Println("Error releasing seats of cancelled bookings:", err)
+			} else if cancelled > 0 {
+				log.Printf("Released the seats of %d cancelled bookings\n", cancelled)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
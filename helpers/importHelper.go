@@ -0,0 +1,409 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxImportRows is the largest number of rows a single import may contain
+const MaxImportRows = 5000
+
+// ErrInvalidImport wraps every reason a whole import file is rejected
+var ErrInvalidImport = errors.New("invalid import")
+
+// errImportLookup aborts an import when a route cannot be looked up
+var errImportLookup = errors.New("error looking up routes")
+
+// importRow is one parsed row of an import file, or the reason it could not be parsed
+type importRow struct {
+	Row      int
+	Bus      models.Bus
+	Schedule models.Schedule
+	Err      error
+}
+
+// csvRecord gives access to the cells of a CSV row by column name
+type csvRecord map[string]string
+
+func (r csvRecord) int(column string) (int, error) {
+	value := strings.TrimSpace(r[column])
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", column)
+	}
+	return number, nil
+}
+
+func (r csvRecord) float(column string) (float64, error) {
+	value := strings.TrimSpace(r[column])
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", column)
+	}
+	return number, nil
+}
+
+// list splits a cell holding several values separated by semicolons
+func (r csvRecord) list(column string) []string {
+	var values []string
+	for _, value := range strings.Split(r[column], ";") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// layout reads the optional layout_type, rows, columns, aisle_after and decks columns
+func (r csvRecord) layout() (models.SeatLayout, error) {
+	layout := models.SeatLayout{Type: strings.TrimSpace(r["layout_type"])}
+	var err error
+	if layout.Rows, err = r.int("rows"); err != nil {
+		return layout, err
+	}
+	if layout.Columns, err = r.int("columns"); err != nil {
+		return layout, err
+	}
+	if layout.Aisle_after, err = r.int("aisle_after"); err != nil {
+		return layout, err
+	}
+	if layout.Decks, err = r.int("decks"); err != nil {
+		return layout, err
+	}
+	return layout, nil
+}
+
+func (r csvRecord) bus() (models.Bus, error) {
+	bus := models.Bus{
+		Route_id:       strings.TrimSpace(r["route_id"]),
+		Date:           strings.TrimSpace(r["date"]),
+		Departure_time: strings.TrimSpace(r["departure_time"]),
+		Bus_type:       strings.TrimSpace(r["bus_type"]),
+	}
+	var err error
+	if value := strings.TrimSpace(r["departure_at"]); value != "" {
+		if bus.Departure_at, err = time.Parse(time.RFC3339, value); err != nil {
+			return bus, fmt.Errorf("departure_at must be an RFC 3339 timestamp")
+		}
+	}
+	if bus.Fare, err = r.float("fare"); err != nil {
+		return bus, err
+	}
+	if bus.SeatsTotal, err = r.int("seats_total"); err != nil {
+		return bus, err
+	}
+	bus.Layout, err = r.layout()
+	return bus, err
+}
+
+func (r csvRecord) schedule() (models.Schedule, error) {
+	schedule := models.Schedule{
+		Route_id:       strings.TrimSpace(r["route_id"]),
+		Departure_time: strings.TrimSpace(r["departure_time"]),
+		Bus_type:       strings.TrimSpace(r["bus_type"]),
+		Blackout_dates: r.list("blackout_dates"),
+	}
+	for _, value := range r.list("weekdays") {
+		weekday, err := strconv.Atoi(value)
+		if err != nil {
+			return schedule, fmt.Errorf("weekdays must be numbers separated by semicolons")
+		}
+		schedule.Weekdays = append(schedule.Weekdays, weekday)
+	}
+	var err error
+	if schedule.Fare, err = r.float("fare"); err != nil {
+		return schedule, err
+	}
+	if schedule.SeatsTotal, err = r.int("seats_total"); err != nil {
+		return schedule, err
+	}
+	if schedule.Horizon_days, err = r.int("horizon_days"); err != nil {
+		return schedule, err
+	}
+	schedule.Layout, err = r.layout()
+	return schedule, err
+}
+
+// parseImportCSV reads a CSV file with a header row naming the columns, which
+// are the JSON field names of buses or schedules plus layout_type, rows,
+// columns, aisle_after and decks for the seat layout. Lists such as weekdays
+// are separated by semicolons.
+func parseImportCSV(kind string, reader io.Reader) ([]importRow, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid CSV header: %v", ErrInvalidImport, err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var rows []importRow
+	for number := 1; ; number++ {
+		cells, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("%w: an import can have at most %d rows", ErrInvalidImport, MaxImportRows)
+		}
+		row := importRow{Row: number}
+		if err != nil {
+			row.Err = fmt.Errorf("invalid CSV: %v", err)
+			rows = append(rows, row)
+			continue
+		}
+		if len(cells) != len(header) {
+			row.Err = fmt.Errorf("expected %d columns, found %d", len(header), len(cells))
+			rows = append(rows, row)
+			continue
+		}
+
+		record := make(csvRecord, len(header))
+		for i, column := range header {
+			record[column] = cells[i]
+		}
+		if kind == models.ImportSchedules {
+			row.Schedule, row.Err = record.schedule()
+		} else {
+			row.Bus, row.Err = record.bus()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportJSON reads a JSON array of buses or schedules in the same format
+// the AddBus and AddSchedule endpoints accept
+func parseImportJSON(kind string, reader io.Reader) ([]importRow, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(reader).Decode(&raws); err != nil {
+		return nil, fmt.Errorf("%w: the file must be a JSON array: %v", ErrInvalidImport, err)
+	}
+	if len(raws) > MaxImportRows {
+		return nil, fmt.Errorf("%w: an import can have at most %d rows", ErrInvalidImport, MaxImportRows)
+	}
+
+	rows := make([]importRow, 0, len(raws))
+	for i, raw := range raws {
+		row := importRow{Row: i + 1}
+		if kind == models.ImportSchedules {
+			row.Err = json.Unmarshal(raw, &row.Schedule)
+		} else {
+			row.Err = json.Unmarshal(raw, &row.Bus)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ImportFromReader validates every bus or schedule in a CSV or JSON file and,
+// unless it is a dry run, inserts the valid ones with a single bulk write.
+// Rows matching an existing record or an earlier row of the file are
+// skipped as duplicates: trips on the same route departing at the same time,
+// and active schedules on the same route departing at the same time on the
+// same weekdays.
+func ImportFromReader(ctx context.Context, kind string, format string, reader io.Reader, dryRun bool) (*models.ImportResult, error) {
+	if kind != models.ImportBuses && kind != models.ImportSchedules {
+		return nil, fmt.Errorf("%w: kind must be %s or %s", ErrInvalidImport, models.ImportBuses, models.ImportSchedules)
+	}
+
+	var rows []importRow
+	var err error
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(kind, reader)
+	case "json":
+		rows, err = parseImportJSON(kind, reader)
+	default:
+		return nil, fmt.Errorf("%w: format must be csv or json", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ImportResult{
+		Kind:       kind,
+		Dry_run:    dryRun,
+		Rows:       len(rows),
+		Duplicates: []int{},
+		Errors:     []models.ImportRowError{},
+	}
+
+	routes := make(map[string]*models.Route)
+	seen := make(map[string]bool)
+	var documents []mongo.WriteModel
+	var documentRows []int
+	for _, row := range rows {
+		document, key, err := buildImportRow(ctx, kind, row, routes)
+		if err == errImportLookup {
+			return nil, err
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportRowError{Row: row.Row, Error: err.Error()})
+			continue
+		}
+		result.Valid++
+
+		if seen[key] {
+			result.Duplicates = append(result.Duplicates, row.Row)
+			continue
+		}
+		seen[key] = true
+
+		exists, err := importDuplicateExists(ctx, kind, document)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			result.Duplicates = append(result.Duplicates, row.Row)
+			continue
+		}
+		documents = append(documents, mongo.NewInsertOneModel().SetDocument(document))
+		documentRows = append(documentRows, row.Row)
+	}
+
+	if dryRun || len(documents) == 0 {
+		return result, nil
+	}
+
+	collection := busCollection
+	if kind == models.ImportSchedules {
+		collection = scheduleCollection
+	}
+	writeResult, err := collection.BulkWrite(ctx, documents, options.BulkWrite().SetOrdered(false))
+	if writeResult != nil {
+		result.Inserted = int(writeResult.InsertedCount)
+	}
+
+	// Trips inserted by someone else since the duplicate check are skipped too
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr) {
+				return result, err
+			}
+		}
+		for _, writeErr := range bulkErr.WriteErrors {
+			result.Duplicates = append(result.Duplicates, documentRows[writeErr.Index])
+		}
+		sort.Ints(result.Duplicates)
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// buildImportRow validates a parsed row into the document to insert and the
+// key identifying duplicates within the file
+func buildImportRow(ctx context.Context, kind string, row importRow, routes map[string]*models.Route) (interface{}, string, error) {
+	if row.Err != nil {
+		return nil, "", row.Err
+	}
+
+	routeID := row.Bus.Route_id
+	if kind == models.ImportSchedules {
+		routeID = row.Schedule.Route_id
+	}
+	if routeID == "" {
+		return nil, "", fmt.Errorf("route_id is required")
+	}
+	route, cached := routes[routeID]
+	if !cached {
+		var err error
+		route, err = GetRouteByRouteId(ctx, routeID)
+		if err != nil {
+			log.Println("Error looking up route", routeID, err)
+			return nil, "", errImportLookup
+		}
+		routes[routeID] = route
+	}
+	if route == nil {
+		return nil, "", fmt.Errorf("route %s not found", routeID)
+	}
+
+	if kind == models.ImportSchedules {
+		if err := ValidateSchedule(row.Schedule); err != nil {
+			return nil, "", err
+		}
+		schedule, err := NewSchedule(route, row.Schedule)
+		if err != nil {
+			return nil, "", err
+		}
+		return schedule, schedule.Route_id + " " + schedule.Departure_time + " " + fmt.Sprint(weekdaySet(schedule.Weekdays)), nil
+	}
+
+	bus, err := NewBus(route, row.Bus)
+	if err != nil {
+		return nil, "", err
+	}
+	return bus, bus.Route_id + " " + bus.Departure_at.Format(time.RFC3339), nil
+}
+
+// weekdaySet returns the distinct weekdays of a schedule in order, so
+// schedules running on the same days compare equal
+func weekdaySet(weekdays []int) []int {
+	seen := make(map[int]bool)
+	set := []int{}
+	for _, weekday := range weekdays {
+		if !seen[weekday] {
+			seen[weekday] = true
+			set = append(set, weekday)
+		}
+	}
+	sort.Ints(set)
+	return set
+}
+
+// importDuplicateExists checks whether a record like the one being imported is already stored
+func importDuplicateExists(ctx context.Context, kind string, document interface{}) (bool, error) {
+	var count int64
+	var err error
+	switch record := document.(type) {
+	case models.Bus:
+		count, err = busCollection.CountDocuments(ctx, bson.M{
+			"route_id": record.Route_id,
+			"$or": bson.A{
+				bson.M{"departure_at": record.Departure_at},
+				bson.M{"date": record.Date, "departure_time": record.Departure_time},
+			},
+		})
+	case models.Schedule:
+		weekdays := weekdaySet(record.Weekdays)
+		count, err = scheduleCollection.CountDocuments(ctx, bson.M{
+			"route_id":       record.Route_id,
+			"departure_time": record.Departure_time,
+			"weekdays":       bson.M{"$all": weekdays, "$size": len(weekdays)},
+			"active":         true,
+		})
+	}
+	return count > 0, err
+}
@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the app relies on. Only one trip may
+// leave on a route at a given instant, so trip generators and imports running
+// at the same time cannot both create it, a user waits for a bus at most once
+// and a hold is checked out by at most one open booking.
+func EnsureIndexes(ctx context.Context) error {
+	_, err := busCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "route_id", Value: 1}, {Key: "departure_at", Value: 1}},
+		Options: options.Index().
+			SetName("route_departure_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"departure_at": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Concurrent joins of the same waitlist must not queue the user twice
+	_, err = waitlistCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "bus_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().
+			SetName("waiting_user_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.WaitlistWaiting}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Concurrent checkouts of the same hold must not both start a payment.
+	// Filtering with $in needs MongoDB 6.0 or later.
+	_, err = bookingCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "hold_id", Value: 1}},
+		Options: options.Index().
+			SetName("open_booking_hold_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{
+				"hold_id": bson.M{"$exists": true},
+				"status":  bson.M{"$in": bson.A{models.BookingPending, models.BookingConfirmed}},
+			}),
+	})
+	return err
+}
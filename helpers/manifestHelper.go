@@ -0,0 +1,202 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// getUsersByUids retrieves the users with the given user_ids, keyed by user_id
+func getUsersByUids(ctx context.Context, userIDs []string) (map[string]models.User, error) {
+	if len(userIDs) == 0 {
+		return map[string]models.User{}, nil
+	}
+
+	var users []models.User
+	projection := bson.M{"user_id": 1, "username": 1, "email": 1, "phone": 1}
+	cursor, err := userCollection.Find(ctx, bson.M{"user_id": bson.M{"$in": userIDs}}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	byUid := make(map[string]models.User, len(users))
+	for _, user := range users {
+		byUid[user.UserID] = user
+	}
+	return byUid, nil
+}
+
+// GetManifest builds the boarding manifest of a bus from its confirmed
+// bookings, one row per seat, ordered by the seat map
+func GetManifest(ctx context.Context, bus *models.Bus) ([]models.ManifestRow, error) {
+	var bookings []models.Booking
+	cursor, err := bookingCollection.Find(ctx, bson.M{"bus_id": bus.Bus_id, "status": models.BookingConfirmed})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &bookings); err != nil {
+		return nil, err
+	}
+
+	userIDs := []string{}
+	for _, booking := range bookings {
+		userIDs = append(userIDs, booking.User_id)
+	}
+	users, err := getUsersByUids(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	route, err := GetRouteByRouteId(ctx, bus.Route_id)
+	if err != nil {
+		return nil, err
+	}
+	return buildManifest(bus, route, bookings, users), nil
+}
+
+// buildManifest lists the seats of the given bookings, one row per seat,
+// ordered by the seat map of the bus. The route may be nil.
+func buildManifest(bus *models.Bus, route *models.Route, bookings []models.Booking, users map[string]models.User) []models.ManifestRow {
+	// Boarding points are shown by stop name rather than city
+	stopNames := make(map[string]string)
+	if route != nil {
+		for _, stop := range route.Stops {
+			if _, exists := stopNames[strings.ToLower(stop.City)]; !exists {
+				stopNames[strings.ToLower(stop.City)] = stop.Name
+			}
+		}
+	}
+
+	rows := []models.ManifestRow{}
+	for _, booking := range bookings {
+		user := users[booking.User_id]
+		boardingPoint := booking.From
+		if name, exists := stopNames[strings.ToLower(booking.From)]; exists {
+			boardingPoint = name
+		}
+
+		// Orders with passenger details list every traveller by name
+		if len(booking.Passengers) > 0 {
+			for _, passenger := range booking.Passengers {
+				rows = append(rows, models.ManifestRow{
+					Seat_no:        passenger.Seat_no,
+					Passenger:      passenger.Name,
+					Phone:          user.Phone,
+					Boarding_point: boardingPoint,
+					Booking_id:     booking.Booking_id,
+				})
+			}
+			continue
+		}
+
+		seats := booking.Seat_numbers
+		if len(seats) == 0 {
+			seats = make([]string, booking.Seats) // Buses without a seat map have unnumbered seats
+		}
+		for _, seatNo := range seats {
+			rows = append(rows, models.ManifestRow{
+				Seat_no:        seatNo,
+				Passenger:      user.Username,
+				Phone:          user.Phone,
+				Boarding_point: boardingPoint,
+				Booking_id:     booking.Booking_id,
+			})
+		}
+	}
+
+	sortManifest(bus, rows)
+	return rows
+}
+
+// UnnumberedSeat labels the manifest rows of seats on buses without a seat map
+const UnnumberedSeat = "unnumbered"
+
+// sortManifest orders manifest rows the way the seats are laid out in the
+// seat map of the bus, so "2A" comes before "10A". Seats missing from the
+// seat map come last, and unnumbered seats get a placeholder label.
+func sortManifest(bus *models.Bus, rows []models.ManifestRow) {
+	position := make(map[string]int, len(bus.Seats))
+	for i, seat := range bus.Seats {
+		position[seat.Seat_no] = i
+	}
+	rank := func(seatNo string) int {
+		if i, exists := position[seatNo]; exists {
+			return i
+		}
+		return len(bus.Seats)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rank(rows[i].Seat_no) < rank(rows[j].Seat_no)
+	})
+	for i := range rows {
+		if rows[i].Seat_no == "" {
+			rows[i].Seat_no = UnnumberedSeat
+		}
+	}
+}
+
+// manifestHeader is the column header of manifest exports
+var manifestHeader = []string{"Seat", "Passenger", "Phone", "Boarding point", "Booking"}
+
+// WriteManifestCSV streams a manifest as CSV
+func WriteManifestCSV(w io.Writer, rows []models.ManifestRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(manifestHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writer.Write([]string{row.Seat_no, row.Passenger, row.Phone, row.Boarding_point, row.Booking_id}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteManifestPDF renders a manifest as a printable PDF table
+func WriteManifestPDF(w io.Writer, bus *models.Bus, rows []models.ManifestRow) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, "Boarding manifest")
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.Cell(0, 7, bus.Origin+" to "+bus.Destination+", "+bus.Date+" "+bus.Departure_time+" (bus "+bus.Bus_id+")")
+	pdf.Ln(12)
+
+	widths := []float64{18, 50, 35, 45, 42}
+	pdf.SetFont("Helvetica", "B", 10)
+	for i, title := range manifestHeader {
+		pdf.CellFormat(widths[i], 8, title, "1", 0, "L", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, row := range rows {
+		for i, value := range []string{row.Seat_no, row.Passenger, row.Phone, row.Boarding_point, row.Booking_id} {
+			pdf.CellFormat(widths[i], 7, value, "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return err
+	}
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
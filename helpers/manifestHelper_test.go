@@ -0,0 +1,87 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetUsersByUidsWithoutUsers(t *testing.T) {
+	// No database is reachable in tests, so this only passes without a query
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	users, err := getUsersByUids(ctx, []string{})
+	if err != nil {
+		t.Fatalf("getUsersByUids() error = %v", err)
+	}
+	if users == nil || len(users) != 0 {
+		t.Errorf("getUsersByUids() = %v, want an empty map", users)
+	}
+}
+
+func TestBuildManifest(t *testing.T) {
+	bus := &models.Bus{Seats: []models.Seat{{Seat_no: "1A"}, {Seat_no: "2A"}, {Seat_no: "10A"}}}
+	route := &models.Route{Stops: []models.Stop{{Name: "Majestic", City: "Bengaluru"}, {Name: "Koyambedu", City: "Chennai"}}}
+	users := map[string]models.User{"user-1": {Username: "Asha", Phone: "98450"}}
+
+	tests := []struct {
+		name     string
+		bus      *models.Bus
+		route    *models.Route
+		bookings []models.Booking
+		want     []models.ManifestRow
+	}{
+		{
+			name:  "bus without bookings",
+			bus:   bus,
+			route: route,
+			want:  []models.ManifestRow{},
+		},
+		{
+			name:  "seats follow the seat map",
+			bus:   bus,
+			route: route,
+			bookings: []models.Booking{
+				{Booking_id: "b1", User_id: "user-1", From: "bengaluru", Seat_numbers: []string{"10A", "2A"}},
+			},
+			want: []models.ManifestRow{
+				{Seat_no: "2A", Passenger: "Asha", Phone: "98450", Boarding_point: "Majestic", Booking_id: "b1"},
+				{Seat_no: "10A", Passenger: "Asha", Phone: "98450", Boarding_point: "Majestic", Booking_id: "b1"},
+			},
+		},
+		{
+			name:  "passengers are listed by name",
+			bus:   bus,
+			route: nil,
+			bookings: []models.Booking{
+				{Booking_id: "b2", User_id: "user-1", From: "Mysuru", Passengers: []models.Passenger{{Seat_no: "1A", Name: "Ravi"}}},
+			},
+			want: []models.ManifestRow{
+				{Seat_no: "1A", Passenger: "Ravi", Phone: "98450", Boarding_point: "Mysuru", Booking_id: "b2"},
+			},
+		},
+		{
+			name:  "unnumbered seats",
+			bus:   &models.Bus{},
+			route: route,
+			bookings: []models.Booking{
+				{Booking_id: "b3", User_id: "user-2", From: "Chennai", Seats: 2},
+			},
+			want: []models.ManifestRow{
+				{Seat_no: UnnumberedSeat, Boarding_point: "Koyambedu", Booking_id: "b3"},
+				{Seat_no: UnnumberedSeat, Boarding_point: "Koyambedu", Booking_id: "b3"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := buildManifest(test.bus, test.route, test.bookings, users)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("buildManifest() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"fmt"
+)
+
+// Concession rules for passengers, applied to the per-seat price
+const (
+	ChildMaxAge           = 11
+	ChildDiscountPercent  = 50
+	SeniorMinAge          = 60
+	SeniorDiscountPercent = 25
+)
+
+// passengerGenders and passengerIdTypes are the accepted passenger details
+var passengerGenders = map[string]bool{"male": true, "female": true, "other": true}
+var passengerIdTypes = map[string]bool{"aadhaar": true, "passport": true, "driving_license": true, "voter_id": true, "pan": true}
+
+// ValidatePassengers checks the details of every passenger of an order. Seat
+// numbers must be given for all passengers or for none of them.
+func ValidatePassengers(passengers []models.Passenger) error {
+	withSeat := 0
+	seen := make(map[string]bool)
+	for i, passenger := range passengers {
+		if passenger.Name == "" {
+			return fmt.Errorf("passenger %d needs a name", i+1)
+		}
+		// A missing age decodes as 0, which would otherwise earn the child concession
+		if passenger.Age < 1 || passenger.Age > 120 {
+			return fmt.Errorf("passenger %s needs an age between 1 and 120", passenger.Name)
+		}
+		if !passengerGenders[passenger.Gender] {
+			return fmt.Errorf("passenger %s needs a gender of male, female or other", passenger.Name)
+		}
+		if !passengerIdTypes[passenger.Id_type] {
+			return fmt.Errorf("passenger %s has an unknown id_type %q", passenger.Name, passenger.Id_type)
+		}
+		if passenger.Seat_no != "" {
+			if seen[passenger.Seat_no] {
+				return fmt.Errorf("seat %s is assigned to more than one passenger", passenger.Seat_no)
+			}
+			seen[passenger.Seat_no] = true
+			withSeat++
+		}
+	}
+	if withSeat != 0 && withSeat != len(passengers) {
+		return fmt.Errorf("give a seat_no for every passenger or for none of them")
+	}
+	return nil
+}
+
+// PassengerSeatNumbers returns the seats the passengers asked for, if any
+func PassengerSeatNumbers(passengers []models.Passenger) []string {
+	var seatNumbers []string
+	for _, passenger := range passengers {
+		if passenger.Seat_no != "" {
+			seatNumbers = append(seatNumbers, passenger.Seat_no)
+		}
+	}
+	return seatNumbers
+}
+
+// concession returns the concession a passenger is entitled to and its discount
+func concession(passenger models.Passenger) (string, int) {
+	switch {
+	case passenger.Age <= ChildMaxAge:
+		return models.ConcessionChild, ChildDiscountPercent
+	case passenger.Age >= SeniorMinAge:
+		return models.ConcessionSenior, SeniorDiscountPercent
+	}
+	return "", 0
+}
+
+// PricePassengers prices every passenger individually from the per-seat
+// price of the breakdown, adding a line for each concession and updating the total
+func PricePassengers(breakdown *models.PriceBreakdown, passengers []models.Passenger) {
+	total := 0.0
+	for i := range passengers {
+		passenger := &passengers[i]
+		passenger.Fare = breakdown.Per_seat
+		passenger.Concession = ""
+
+		name, percent := concession(*passenger)
+		if percent > 0 {
+			discount := roundCents(breakdown.Per_seat * float64(percent) / 100)
+			passenger.Concession = name
+			passenger.Fare = roundCents(breakdown.Per_seat - discount)
+			breakdown.Items = append(breakdown.Items, models.PriceItem{
+				Label:  fmt.Sprintf("%s concession (%s)", name, passenger.Name),
+				Amount: -roundCents(discount),
+			})
+		}
+		total += passenger.Fare
+	}
+	breakdown.Total = roundCents(total)
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"testing"
+)
+
+func TestConcession(t *testing.T) {
+	tests := []struct {
+		age         int
+		wantName    string
+		wantPercent int
+	}{
+		{1, models.ConcessionChild, ChildDiscountPercent},
+		{ChildMaxAge, models.ConcessionChild, ChildDiscountPercent},
+		{ChildMaxAge + 1, "", 0},
+		{SeniorMinAge - 1, "", 0},
+		{SeniorMinAge, models.ConcessionSenior, SeniorDiscountPercent},
+		{90, models.ConcessionSenior, SeniorDiscountPercent},
+	}
+	for _, test := range tests {
+		name, percent := concession(models.Passenger{Age: test.age})
+		if name != test.wantName || percent != test.wantPercent {
+			t.Errorf("concession(age %d) = %q, %d, want %q, %d", test.age, name, percent, test.wantName, test.wantPercent)
+		}
+	}
+}
+
+func TestValidatePassengers(t *testing.T) {
+	passenger := func(seat string, age int) models.Passenger {
+		return models.Passenger{Seat_no: seat, Name: "Asha", Age: age, Gender: "female", Id_type: "passport"}
+	}
+
+	tests := []struct {
+		name       string
+		passengers []models.Passenger
+		wantErr    bool
+	}{
+		{"valid passengers with seats", []models.Passenger{passenger("1A", 30), passenger("1B", 8)}, false},
+		{"valid passengers without seats", []models.Passenger{passenger("", 30), passenger("", 65)}, false},
+		{"missing age", []models.Passenger{passenger("", 0)}, true},
+		{"negative age", []models.Passenger{passenger("", -3)}, true},
+		{"age above 120", []models.Passenger{passenger("", 121)}, true},
+		{"missing name", []models.Passenger{{Age: 30, Gender: "male", Id_type: "pan"}}, true},
+		{"unknown gender", []models.Passenger{{Name: "Ravi", Age: 30, Gender: "x", Id_type: "pan"}}, true},
+		{"unknown id type", []models.Passenger{{Name: "Ravi", Age: 30, Gender: "male", Id_type: "library_card"}}, true},
+		{"seat given twice", []models.Passenger{passenger("1A", 30), passenger("1A", 31)}, true},
+		{"seat given for some passengers only", []models.Passenger{passenger("1A", 30), passenger("", 31)}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidatePassengers(test.passengers); (err != nil) != test.wantErr {
+				t.Errorf("ValidatePassengers() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPricePassengers(t *testing.T) {
+	breakdown := models.PriceBreakdown{Per_seat: 500, Items: []models.PriceItem{}}
+	passengers := []models.Passenger{
+		{Name: "Adult", Age: 35},
+		{Name: "Child", Age: 6},
+		{Name: "Senior", Age: 70},
+	}
+
+	PricePassengers(&breakdown, passengers)
+
+	wantFares := []float64{500, 250, 375}
+	for i, want := range wantFares {
+		if passengers[i].Fare != want {
+			t.Errorf("fare of %s = %v, want %v", passengers[i].Name, passengers[i].Fare, want)
+		}
+	}
+	if breakdown.Total != 1125 {
+		t.Errorf("total = %v, want 1125", breakdown.Total)
+	}
+	if len(breakdown.Items) != 2 {
+		t.Errorf("got %d concession lines, want 2", len(breakdown.Items))
+	}
+}
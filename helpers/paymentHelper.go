@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"busapp/payments"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PaymentProvider is the gateway bookings are paid through, see SetupPayments
+var PaymentProvider payments.PaymentProvider
+
+// SetupPayments picks the payment provider with PAYMENT_PROVIDER (the
+// in-process fake provider by default). PAYMENT_WEBHOOK_SECRET is required,
+// since webhooks signed with an empty secret could be forged by anyone.
+func SetupPayments() {
+	if os.Getenv("PAYMENT_WEBHOOK_SECRET") == "" {
+		log.Fatal("PAYMENT_WEBHOOK_SECRET must be set")
+	}
+	provider, err := payments.New(os.Getenv("PAYMENT_PROVIDER"), os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	PaymentProvider = provider
+}
+
+// FakePaymentsEnabled reports whether the fake provider was chosen explicitly
+// through PAYMENT_PROVIDER, which allows completing payments without paying
+func FakePaymentsEnabled() bool {
+	return os.Getenv("PAYMENT_PROVIDER") == "fake"
+}
+
+// PaymentCurrency returns the currency bookings are charged in, configured through PAYMENT_CURRENCY
+func PaymentCurrency() string {
+	if currency := os.Getenv("PAYMENT_CURRENCY"); currency != "" {
+		return currency
+	}
+	return "INR"
+}
+
+// GetBookingByPaymentIntentId retrieves the booking paid by a payment intent
+func GetBookingByPaymentIntentId(ctx context.Context, intentID string) (*models.Booking, error) {
+	var booking models.Booking
+	err := bookingCollection.FindOne(ctx, bson.M{"payment_intent_id": intentID}).Decode(&booking)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Booking not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// finishPendingBooking moves a pending booking to its final status and records why
+func finishPendingBooking(ctx context.Context, booking *models.Booking, status string, note string) (*models.Booking, error) {
+	now := time.Now()
+	filter := bson.M{"booking_id": booking.Booking_id, "status": models.BookingPending}
+	update := bson.M{
+		"$set":  bson.M{"status": status, "updated_at": now},
+		"$push": bson.M{"history": models.BookingEvent{Status: status, Amount: booking.Amount, Note: note, At: now}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var finished models.Booking
+	err := bookingCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&finished)
+	if err != nil {
+		return nil, err
+	}
+	return &finished, nil
+}
+
+// ConfirmBookingPayment books the held seats of the booking paid by the
+// intent and captures the payment. When the hold expired before the payment
+// arrived the booking fails instead and the payment is never captured. When
+// the booking stops being pending during capture the payment is refunded.
+func ConfirmBookingPayment(ctx context.Context, intentID string) (*models.Booking, error) {
+	booking, err := GetBookingByPaymentIntentId(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if booking == nil {
+		return nil, ErrBookingNotFound
+	}
+	if booking.Status != models.BookingPending {
+		return booking, nil // Webhooks can be delivered more than once
+	}
+
+	if _, err := ConvertHold(ctx, booking.Hold_id, booking.User_id); err != nil {
+		if err == ErrHoldNotActive {
+			hold, holdErr := GetHoldByHoldId(ctx, booking.Hold_id)
+			if holdErr != nil {
+				return nil, holdErr
+			}
+			if hold != nil && hold.Status == models.HoldConverted {
+				return booking, nil // Another delivery of the same webhook is confirming the booking
+			}
+			return finishPendingBooking(ctx, booking, models.BookingFailed, "hold expired before payment")
+		}
+		return nil, err
+	}
+
+	if err := PaymentProvider.Capture(ctx, intentID); err != nil {
+		if releaseErr := ReleaseBookedSeats(ctx, booking); releaseErr != nil {
+			return nil, fmt.Errorf("failed to capture payment: %v (releasing seats also failed: %v)", err, releaseErr)
+		}
+		go seatsFreed(context.Background(), booking.Bus_id)
+		return finishPendingBooking(ctx, booking, models.BookingFailed, fmt.Sprintf("capture failed: %v", err))
+	}
+
+	confirmed, err := finishPendingBooking(ctx, booking, models.BookingConfirmed, "payment captured")
+	if err == mongo.ErrNoDocuments {
+		// The booking stopped being pending while its payment was captured,
+		// for instance because its trip was cancelled in the meantime
+		return refundLatePayment(ctx, booking)
+	}
+	return confirmed, err
+}
+
+// refundLatePayment undoes a payment captured for a booking that was failed
+// or cancelled before it could be confirmed: the seats booked for it are
+// given back and the whole payment is refunded.
+func refundLatePayment(ctx context.Context, booking *models.Booking) (*models.Booking, error) {
+	current, err := GetBookingByBookingId(ctx, booking.Booking_id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrBookingNotFound
+	}
+	if current.Status == models.BookingConfirmed {
+		return current, nil // Confirmed by another delivery of the same webhook
+	}
+
+	if err := ReleaseBookedSeats(ctx, booking); err != nil {
+		return nil, err
+	}
+	go seatsFreed(context.Background(), booking.Bus_id)
+
+	if err := RefundBookingPayment(ctx, booking, booking.Amount); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"refund_amount": booking.Amount, "updated_at": now},
+		"$push": bson.M{"history": models.BookingEvent{
+			Status: current.Status,
+			Amount: booking.Amount,
+			Note:   fmt.Sprintf("payment refunded, booking was %s before it could be confirmed", current.Status),
+			At:     now,
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var refunded models.Booking
+	err = bookingCollection.FindOneAndUpdate(ctx, bson.M{"booking_id": booking.Booking_id}, update, opts).Decode(&refunded)
+	if err != nil {
+		return nil, err
+	}
+	return &refunded, nil
+}
+
+// FailBookingPayment fails the booking of an unsuccessful payment and gives its held seats back
+func FailBookingPayment(ctx context.Context, intentID string) (*models.Booking, error) {
+	booking, err := GetBookingByPaymentIntentId(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if booking == nil {
+		return nil, ErrBookingNotFound
+	}
+	if booking.Status != models.BookingPending {
+		return booking, nil // Webhooks can be delivered more than once
+	}
+
+	if err := ReleaseHold(ctx, booking.Hold_id, booking.User_id); err != nil && err != ErrHoldNotActive {
+		return nil, err
+	}
+
+	return finishPendingBooking(ctx, booking, models.BookingFailed, "payment failed")
+}
+
+// RefundBookingPayment pays back a refund through the provider of the booking payment
+func RefundBookingPayment(ctx context.Context, booking *models.Booking, amount float64) error {
+	if booking.Payment_intent_id == "" || amount <= 0 {
+		return nil
+	}
+	return PaymentProvider.Refund(ctx, booking.Payment_intent_id, amount)
+}
@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	models "busapp/models"
+)
+
+// rolePermissions lists what each role may do. Customers book for
+// themselves, agents book on behalf of customers, support helps customers,
+// operators run the buses and admins may do everything.
+var rolePermissions = map[string][]string{
+	models.RoleCustomer: {models.PermBookTrips},
+	models.RoleAgent:    {models.PermBookTrips, models.PermViewBuses},
+	models.RoleSupport:  {models.PermBookTrips, models.PermViewBuses, models.PermViewManifests, models.PermViewUsers},
+	models.RoleOperator: {models.PermOperateTrips, models.PermViewBuses, models.PermManageBuses, models.PermViewManifests, models.PermManageSchedules, models.PermManageRefunds},
+	models.RoleAdmin: {
+		models.PermBookTrips, models.PermOperateTrips, models.PermViewBuses, models.PermManageBuses,
+		models.PermViewManifests, models.PermManageRoutes, models.PermManageRefunds, models.PermManageSchedules,
+		models.PermManagePricing, models.PermViewUsers, models.PermManageUsers,
+	},
+}
+
+// roleRanks orders the roles from the least to the most trusted
+var roleRanks = map[string]int{
+	models.RoleCustomer: 0,
+	models.RoleAgent:    1,
+	models.RoleSupport:  2,
+	models.RoleOperator: 3,
+	models.RoleAdmin:    4,
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, exists := rolePermissions[role]
+	return exists
+}
+
+// UserRole returns the role of a user. Users who signed up without a role
+// are customers.
+func UserRole(user *models.User) string {
+	if user.Role == "" {
+		return models.RoleCustomer
+	}
+	return user.Role
+}
+
+// CanAssignRole reports whether a user with the assigner role may give a
+// role to someone, which is never a role ranked above their own
+func CanAssignRole(assigner string, role string) bool {
+	if assigner == "" {
+		assigner = models.RoleCustomer
+	}
+	assignerRank, known := roleRanks[assigner]
+	if !known {
+		return false
+	}
+	rank, known := roleRanks[role]
+	return known && rank <= assignerRank
+}
+
+// HasPermission reports whether a role grants a permission. Users who signed
+// up without a role are customers.
+func HasPermission(role string, permission string) bool {
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	tests := []struct {
+		role       string
+		permission string
+		want       bool
+	}{
+		{"", models.PermBookTrips, true},
+		{"", models.PermViewBuses, false},
+		{models.RoleCustomer, models.PermBookTrips, true},
+		{models.RoleCustomer, models.PermManageUsers, false},
+		{models.RoleAgent, models.PermViewBuses, true},
+		{models.RoleAgent, models.PermViewManifests, false},
+		{models.RoleSupport, models.PermViewUsers, true},
+		{models.RoleSupport, models.PermManageUsers, false},
+		{models.RoleOperator, models.PermOperateTrips, true},
+		{models.RoleOperator, models.PermManageRefunds, true},
+		{models.RoleOperator, models.PermManagePricing, false},
+		{models.RoleOperator, models.PermBookTrips, false},
+		{models.RoleAdmin, models.PermManageUsers, true},
+		{models.RoleAdmin, models.PermManageRefunds, true},
+		{"superuser", models.PermBookTrips, false},
+	}
+	for _, test := range tests {
+		if got := HasPermission(test.role, test.permission); got != test.want {
+			t.Errorf("HasPermission(%q, %s) = %v, want %v", test.role, test.permission, got, test.want)
+		}
+	}
+}
+
+func TestAdminHasEveryPermission(t *testing.T) {
+	for role, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			if !HasPermission(models.RoleAdmin, permission) {
+				t.Errorf("admin lacks %s of %s", permission, role)
+			}
+		}
+	}
+}
+
+func TestCanAssignRole(t *testing.T) {
+	tests := []struct {
+		assigner string
+		role     string
+		want     bool
+	}{
+		{models.RoleAdmin, models.RoleAdmin, true},
+		{models.RoleAdmin, models.RoleCustomer, true},
+		{models.RoleOperator, models.RoleOperator, true},
+		{models.RoleOperator, models.RoleSupport, true},
+		{models.RoleOperator, models.RoleAdmin, false},
+		{models.RoleSupport, models.RoleOperator, false},
+		{models.RoleCustomer, models.RoleAgent, false},
+		{"", models.RoleCustomer, true},
+		{"", models.RoleAgent, false},
+		{models.RoleAdmin, "superuser", false},
+		{"superuser", models.RoleCustomer, false},
+	}
+	for _, test := range tests {
+		if got := CanAssignRole(test.assigner, test.role); got != test.want {
+			t.Errorf("CanAssignRole(%q, %q) = %v, want %v", test.assigner, test.role, got, test.want)
+		}
+	}
+}
+
+func TestRolesAreRanked(t *testing.T) {
+	for role := range rolePermissions {
+		if _, ranked := roleRanks[role]; !ranked {
+			t.Errorf("role %s has no rank", role)
+		}
+	}
+}
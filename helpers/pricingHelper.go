@@ -0,0 +1,233 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var fareCollection *mongo.Collection = configs.GetCollection(configs.DB, "fare")
+var pricingRuleCollection *mongo.Collection = configs.GetCollection(configs.DB, "pricing_rule")
+
+// roundCents rounds an amount of money to two decimals
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ValidateFare checks that a fare has a route segment, a seat class and a price
+func ValidateFare(fare models.Fare) error {
+	if fare.Route_id == "" || fare.From == "" || fare.To == "" {
+		return fmt.Errorf("route_id, from and to are required")
+	}
+	if fare.Seat_class != models.LayoutSeater && fare.Seat_class != models.LayoutSleeper {
+		return fmt.Errorf("seat_class must be %q or %q", models.LayoutSeater, models.LayoutSleeper)
+	}
+	if fare.Base_fare <= 0 {
+		return fmt.Errorf("base_fare must be positive")
+	}
+	return nil
+}
+
+// ValidatePricingRule checks that a pricing rule has everything its type needs
+func ValidatePricingRule(rule models.PricingRule) error {
+	if rule.Percent <= 0 || rule.Percent > 100 {
+		return fmt.Errorf("percent must be between 0 and 100")
+	}
+	switch rule.Type {
+	case models.RuleWeekendSurcharge:
+	case models.RuleHolidaySurcharge:
+		if len(rule.Holidays) == 0 {
+			return fmt.Errorf("holiday surcharges need at least one holiday")
+		}
+		for _, date := range rule.Holidays {
+			if _, err := time.Parse(DateFormat, date); err != nil {
+				return fmt.Errorf("holiday %s must be in YYYY-MM-DD format", date)
+			}
+		}
+	case models.RuleOccupancy:
+		if rule.Min_occupancy <= 0 || rule.Min_occupancy > 100 {
+			return fmt.Errorf("min_occupancy must be between 1 and 100")
+		}
+	case models.RuleEarlyBird:
+		if rule.Days_before <= 0 {
+			return fmt.Errorf("days_before must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown pricing rule type %q", rule.Type)
+	}
+	return nil
+}
+
+// UpsertFare stores the base fare of a route segment and seat class, replacing any previous one
+func UpsertFare(ctx context.Context, fare models.Fare) error {
+	filter := bson.M{"route_id": fare.Route_id, "from": fare.From, "to": fare.To, "seat_class": fare.Seat_class}
+	update := bson.M{
+		"$set": bson.M{"base_fare": fare.Base_fare, "updated_at": fare.Updated_at},
+		"$setOnInsert": bson.M{
+			"_id":        fare.ID,
+			"fare_id":    fare.Fare_id,
+			"created_at": fare.Created_at,
+		},
+	}
+	_, err := fareCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetFaresByRouteId retrieves every base fare of a route
+func GetFaresByRouteId(ctx context.Context, routeID string) ([]models.Fare, error) {
+	var fares []models.Fare
+	cursor, err := fareCollection.Find(ctx, bson.M{"route_id": routeID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &fares); err != nil {
+		return nil, err
+	}
+	return fares, nil
+}
+
+// InsertPricingRule stores a new pricing rule
+func InsertPricingRule(ctx context.Context, rule models.PricingRule) error {
+	_, err := pricingRuleCollection.InsertOne(ctx, rule)
+	return err
+}
+
+// GetAllPricingRulesFromDatabase retrieves every pricing rule
+func GetAllPricingRulesFromDatabase(ctx context.Context) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	cursor, err := pricingRuleCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// DeletePricingRuleByRuleId deletes a pricing rule
+func DeletePricingRuleByRuleId(ctx context.Context, ruleID string) error {
+	_, err := pricingRuleCollection.DeleteOne(ctx, bson.M{"rule_id": ruleID})
+	return err
+}
+
+// LoadPricingRules retrieves the active pricing rules, so several quotes can share them
+func LoadPricingRules(ctx context.Context) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	cursor, err := pricingRuleCollection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// baseFare returns the base fare of a segment for the seat class of the bus.
+// Segments without a configured fare fall back to the flat fare of the trip.
+func baseFare(ctx context.Context, bus *models.Bus, from string, to string) (float64, error) {
+	var fare models.Fare
+	err := fareCollection.FindOne(ctx, bson.M{
+		"route_id":   bus.Route_id,
+		"from":       exactMatch(from),
+		"to":         exactMatch(to),
+		"seat_class": bus.Layout.Type,
+	}).Decode(&fare)
+	if err == mongo.ErrNoDocuments {
+		return bus.Fare, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fare.Base_fare, nil
+}
+
+// ruleApplies reports whether a pricing rule applies to a trip departing at
+// departure, given in local time, with the given occupancy percentage, quoted at now
+func ruleApplies(rule models.PricingRule, bus *models.Bus, departure time.Time, occupancy int, now time.Time) bool {
+	if rule.Route_id != "" && rule.Route_id != bus.Route_id {
+		return false
+	}
+	switch rule.Type {
+	case models.RuleWeekendSurcharge:
+		return departure.Weekday() == time.Saturday || departure.Weekday() == time.Sunday
+	case models.RuleHolidaySurcharge:
+		for _, holiday := range rule.Holidays {
+			if holiday == departure.Format(DateFormat) {
+				return true
+			}
+		}
+		return false
+	case models.RuleOccupancy:
+		return occupancy >= rule.Min_occupancy
+	case models.RuleEarlyBird:
+		return departure.Sub(now) >= time.Duration(rule.Days_before)*24*time.Hour
+	}
+	return false
+}
+
+// QuotePrice prices seats on a bus between two cities of its route using the
+// given active pricing rules. Search and holds both use it, so the price
+// quoted to a customer is the price they are charged.
+func QuotePrice(ctx context.Context, rules []models.PricingRule, bus *models.Bus, from string, to string, seats int, now time.Time) (models.PriceBreakdown, error) {
+	base, err := baseFare(ctx, bus, from, to)
+	if err != nil {
+		return models.PriceBreakdown{}, err
+	}
+	// Weekends and holidays are those at the first stop of the route
+	departure, err := TripLocalDeparture(ctx, bus)
+	if err != nil {
+		return models.PriceBreakdown{}, err
+	}
+
+	return priceSeats(base, rules, bus, departure, seats, now), nil
+}
+
+// priceSeats applies the pricing rules to the base fare of seats on a bus
+// departing at departure. Surcharges and discounts are percentages of the
+// base fare, and a seat never costs less than nothing.
+func priceSeats(base float64, rules []models.PricingRule, bus *models.Bus, departure time.Time, seats int, now time.Time) models.PriceBreakdown {
+	occupancy := 0
+	if bus.SeatsTotal > 0 {
+		occupancy = (bus.SeatsBooked + bus.SeatsHeld) * 100 / bus.SeatsTotal
+	}
+
+	breakdown := models.PriceBreakdown{Base_fare: base, Items: []models.PriceItem{}, Seats: seats}
+	perSeat := base
+	for _, rule := range rules {
+		if !ruleApplies(rule, bus, departure, occupancy, now) {
+			continue
+		}
+		amount := roundCents(base * rule.Percent / 100)
+		if rule.Type == models.RuleEarlyBird {
+			amount = -amount
+		}
+		label := rule.Name
+		if label == "" {
+			label = rule.Type
+		}
+		breakdown.Items = append(breakdown.Items, models.PriceItem{Label: label, Amount: amount})
+		perSeat += amount
+	}
+	if perSeat < 0 {
+		perSeat = 0
+	}
+
+	breakdown.Per_seat = roundCents(perSeat)
+	breakdown.Total = roundCents(breakdown.Per_seat * float64(seats))
+	return breakdown
+}
@@ -0,0 +1,215 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleApplies(t *testing.T) {
+	saturday := time.Date(2026, 10, 24, 21, 30, 0, 0, time.UTC)
+	monday := time.Date(2026, 10, 26, 21, 30, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	bus := &models.Bus{Route_id: "route-1"}
+
+	tests := []struct {
+		name      string
+		rule      models.PricingRule
+		departure time.Time
+		occupancy int
+		want      bool
+	}{
+		{"weekend surcharge on a saturday", models.PricingRule{Type: models.RuleWeekendSurcharge}, saturday, 0, true},
+		{"weekend surcharge on a monday", models.PricingRule{Type: models.RuleWeekendSurcharge}, monday, 0, false},
+		{"holiday surcharge on a holiday", models.PricingRule{Type: models.RuleHolidaySurcharge, Holidays: []string{"2026-10-26"}}, monday, 0, true},
+		{"holiday surcharge on another day", models.PricingRule{Type: models.RuleHolidaySurcharge, Holidays: []string{"2026-10-25"}}, monday, 0, false},
+		{"occupancy tier reached", models.PricingRule{Type: models.RuleOccupancy, Min_occupancy: 80}, monday, 80, true},
+		{"occupancy tier not reached", models.PricingRule{Type: models.RuleOccupancy, Min_occupancy: 80}, monday, 79, false},
+		{"early bird booked in time", models.PricingRule{Type: models.RuleEarlyBird, Days_before: 7}, monday, 0, true},
+		{"early bird booked too late", models.PricingRule{Type: models.RuleEarlyBird, Days_before: 10}, monday, 0, false},
+		{"rule of another route", models.PricingRule{Type: models.RuleWeekendSurcharge, Route_id: "route-2"}, saturday, 0, false},
+		{"rule of the same route", models.PricingRule{Type: models.RuleWeekendSurcharge, Route_id: "route-1"}, saturday, 0, true},
+		{"unknown rule type", models.PricingRule{Type: "mystery"}, saturday, 100, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ruleApplies(test.rule, bus, test.departure, test.occupancy, now); got != test.want {
+				t.Errorf("ruleApplies() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPriceSeats(t *testing.T) {
+	saturday := time.Date(2026, 10, 24, 21, 30, 0, 0, time.UTC)
+	monday := time.Date(2026, 10, 26, 21, 30, 0, 0, time.UTC)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	weekend := models.PricingRule{Name: "Weekend", Type: models.RuleWeekendSurcharge, Percent: 10}
+	busy := models.PricingRule{Name: "Busy", Type: models.RuleOccupancy, Min_occupancy: 50, Percent: 20}
+	earlyBird := models.PricingRule{Name: "Early bird", Type: models.RuleEarlyBird, Days_before: 5, Percent: 15}
+	giveaway := models.PricingRule{Name: "Giveaway", Type: models.RuleEarlyBird, Days_before: 1, Percent: 100}
+
+	tests := []struct {
+		name      string
+		base      float64
+		rules     []models.PricingRule
+		bus       models.Bus
+		departure time.Time
+		seats     int
+		wantItems []models.PriceItem
+		wantSeat  float64
+		wantTotal float64
+	}{
+		{
+			name:      "no rules",
+			base:      500,
+			bus:       models.Bus{SeatsTotal: 40},
+			departure: monday,
+			seats:     2,
+			wantItems: []models.PriceItem{},
+			wantSeat:  500,
+			wantTotal: 1000,
+		},
+		{
+			name:      "weekend surcharge",
+			base:      500,
+			rules:     []models.PricingRule{weekend},
+			bus:       models.Bus{SeatsTotal: 40},
+			departure: saturday,
+			seats:     1,
+			wantItems: []models.PriceItem{{Label: "Weekend", Amount: 50}},
+			wantSeat:  550,
+			wantTotal: 550,
+		},
+		{
+			name:      "occupancy counts held seats",
+			base:      500,
+			rules:     []models.PricingRule{busy},
+			bus:       models.Bus{SeatsTotal: 40, SeatsBooked: 15, SeatsHeld: 5},
+			departure: monday,
+			seats:     1,
+			wantItems: []models.PriceItem{{Label: "Busy", Amount: 100}},
+			wantSeat:  600,
+			wantTotal: 600,
+		},
+		{
+			name:      "occupancy below the tier",
+			base:      500,
+			rules:     []models.PricingRule{busy},
+			bus:       models.Bus{SeatsTotal: 40, SeatsBooked: 19},
+			departure: monday,
+			seats:     1,
+			wantItems: []models.PriceItem{},
+			wantSeat:  500,
+			wantTotal: 500,
+		},
+		{
+			name:      "surcharges and discounts are percentages of the base fare",
+			base:      333.33,
+			rules:     []models.PricingRule{weekend, earlyBird},
+			bus:       models.Bus{SeatsTotal: 40},
+			departure: saturday,
+			seats:     3,
+			wantItems: []models.PriceItem{{Label: "Weekend", Amount: 33.33}, {Label: "Early bird", Amount: -50}},
+			wantSeat:  316.66,
+			wantTotal: 949.98,
+		},
+		{
+			name:      "a seat never costs less than nothing",
+			base:      500,
+			rules:     []models.PricingRule{earlyBird, giveaway},
+			bus:       models.Bus{SeatsTotal: 40},
+			departure: monday,
+			seats:     2,
+			wantItems: []models.PriceItem{{Label: "Early bird", Amount: -75}, {Label: "Giveaway", Amount: -500}},
+			wantSeat:  0,
+			wantTotal: 0,
+		},
+		{
+			name:      "rules are labelled with their type without a name",
+			base:      100,
+			rules:     []models.PricingRule{{Type: models.RuleWeekendSurcharge, Percent: 5}},
+			bus:       models.Bus{},
+			departure: saturday,
+			seats:     1,
+			wantItems: []models.PriceItem{{Label: models.RuleWeekendSurcharge, Amount: 5}},
+			wantSeat:  105,
+			wantTotal: 105,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := priceSeats(test.base, test.rules, &test.bus, test.departure, test.seats, now)
+			if !reflect.DeepEqual(got.Items, test.wantItems) {
+				t.Errorf("items = %v, want %v", got.Items, test.wantItems)
+			}
+			if got.Per_seat != test.wantSeat {
+				t.Errorf("per seat = %v, want %v", got.Per_seat, test.wantSeat)
+			}
+			if got.Total != test.wantTotal {
+				t.Errorf("total = %v, want %v", got.Total, test.wantTotal)
+			}
+			if got.Base_fare != test.base || got.Seats != test.seats {
+				t.Errorf("base fare and seats = %v, %v, want %v, %v", got.Base_fare, got.Seats, test.base, test.seats)
+			}
+		})
+	}
+}
+
+func TestValidatePricingRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.PricingRule
+		wantErr bool
+	}{
+		{"weekend surcharge", models.PricingRule{Type: models.RuleWeekendSurcharge, Percent: 10}, false},
+		{"percent of zero", models.PricingRule{Type: models.RuleWeekendSurcharge}, true},
+		{"percent above 100", models.PricingRule{Type: models.RuleWeekendSurcharge, Percent: 101}, true},
+		{"holiday surcharge without holidays", models.PricingRule{Type: models.RuleHolidaySurcharge, Percent: 10}, true},
+		{"holiday in the wrong format", models.PricingRule{Type: models.RuleHolidaySurcharge, Percent: 10, Holidays: []string{"26/10/2026"}}, true},
+		{"occupancy without a tier", models.PricingRule{Type: models.RuleOccupancy, Percent: 10}, true},
+		{"early bird without days", models.PricingRule{Type: models.RuleEarlyBird, Percent: 10}, true},
+		{"unknown type", models.PricingRule{Type: "mystery", Percent: 10}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidatePricingRule(test.rule); (err != nil) != test.wantErr {
+				t.Errorf("ValidatePricingRule() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPriceSeatsInLocalTime(t *testing.T) {
+	// Saturday 03:00 in India is still Friday in UTC
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := &models.Bus{Departure_at: time.Date(2026, 10, 23, 21, 30, 0, 0, time.UTC)}
+	route := &models.Route{Stops: []models.Stop{{Name: "Majestic", City: "Bengaluru", Timezone: "Asia/Kolkata"}}}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	rules := []models.PricingRule{
+		{Name: "Weekend", Type: models.RuleWeekendSurcharge, Percent: 10},
+		{Name: "Holiday", Type: models.RuleHolidaySurcharge, Holidays: []string{"2026-10-24"}, Percent: 20},
+	}
+
+	departure, err := localDeparture(bus, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 24, 3, 0, 0, 0, kolkata); !departure.Equal(want) || departure.Location().String() != "Asia/Kolkata" {
+		t.Fatalf("localDeparture() = %v, want %v", departure, want)
+	}
+
+	got := priceSeats(100, rules, bus, departure, 1, now)
+	want := []models.PriceItem{{Label: "Weekend", Amount: 10}, {Label: "Holiday", Amount: 20}}
+	if !reflect.DeepEqual(got.Items, want) {
+		t.Errorf("items = %v, want %v", got.Items, want)
+	}
+	if got.Total != 130 {
+		t.Errorf("total = %v, want 130", got.Total)
+	}
+}
@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var refreshTokenCollection *mongo.Collection = configs.GetCollection(configs.DB, "refresh_token")
+
+// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+
+// ErrRefreshTokenReused is returned when a refresh token is used a second
+// time, which means it was stolen; its whole family is revoked
+var ErrRefreshTokenReused = errors.New("refresh token was already used, please log in again")
+
+// hashRefreshToken is how refresh tokens are stored, so a database leak does not leak sessions
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshToken creates and stores a new refresh token in a token family
+func issueRefreshToken(ctx context.Context, userID string, familyID string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	_, err := refreshTokenCollection.InsertOne(ctx, models.RefreshToken{
+		Token_hash: hashRefreshToken(token),
+		Family_id:  familyID,
+		User_id:    userID,
+		Status:     models.RefreshActive,
+		Expires_at: time.Now().Add(RefreshTokenTTL()),
+		Created_at: time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeTokenFamily ends the session of a token family: its refresh tokens
+// are revoked and so are the access tokens issued with them
+func RevokeTokenFamily(ctx context.Context, familyID string) error {
+	_, err := refreshTokenCollection.UpdateMany(ctx,
+		bson.M{"family_id": familyID, "status": bson.M{"$ne": models.RefreshRevoked}},
+		bson.M{"$set": bson.M{"status": models.RefreshRevoked}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Access tokens of the family stop working before they expire
+	_, err = revokedTokenCollection.UpdateOne(ctx,
+		bson.M{"family": familyID},
+		bson.M{"$set": models.RevokedToken{
+			Family:     familyID,
+			Expires_at: time.Now().Add(AccessTokenTTL()),
+			Created_at: time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	return endSession(ctx, bson.M{"session_id": familyID})
+}
+
+// RefreshTokens exchanges a refresh token for a new access token and the next
+// refresh token of its family. Each refresh token works once: replaying one
+// that was already exchanged revokes the whole family.
+func RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+	now := time.Now()
+
+	// Use up the token; only one of several concurrent refreshes wins
+	var current models.RefreshToken
+	err := refreshTokenCollection.FindOneAndUpdate(ctx,
+		bson.M{"token_hash": hash, "status": models.RefreshActive, "expires_at": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"status": models.RefreshUsed, "used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return nil, refreshTokenRejected(ctx, hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := GetUserByUid(ctx, current.User_id)
+	if err != nil {
+		return nil, restoreRefreshToken(ctx, &current, err)
+	}
+	if user == nil {
+		// The user was deleted
+		if err := RevokeTokenFamily(ctx, current.Family_id); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokens, err := generateTokenPair(ctx, user.Email, user.Username, user.UserID, user.Role, current.Family_id)
+	if err != nil {
+		return nil, restoreRefreshToken(ctx, &current, err)
+	}
+	if err := extendSession(ctx, current.Family_id); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// restoreRefreshToken makes a refresh token usable again after the refresh
+// failed for a reason of our own, so retrying it is not taken for a replay.
+// It returns the error the refresh failed with.
+func restoreRefreshToken(ctx context.Context, token *models.RefreshToken, cause error) error {
+	_, err := refreshTokenCollection.UpdateOne(ctx,
+		bson.M{"_id": token.ID, "status": models.RefreshUsed},
+		bson.M{"$set": bson.M{"status": models.RefreshActive}, "$unset": bson.M{"used_at": ""}},
+	)
+	if err != nil {
+		log.Println("Error restoring refresh token of family", token.Family_id, err)
+	}
+	return cause
+}
+
+// refreshTokenRejected works out why a refresh token could not be used,
+// revoking its family when it is being replayed
+func refreshTokenRejected(ctx context.Context, hash string) error {
+	var stored models.RefreshToken
+	err := refreshTokenCollection.FindOne(ctx, bson.M{"token_hash": hash}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+	if stored.Status != models.RefreshUsed {
+		return ErrInvalidRefreshToken
+	}
+
+	log.Println("Refresh token reused, revoking token family", stored.Family_id, "of user", stored.User_id)
+	if err := RevokeTokenFamily(ctx, stored.Family_id); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DefaultRefundPolicy is used for routes without their own refund policy:
+// 90% back until 24 hours before departure, 50% after that
+var DefaultRefundPolicy = []models.RefundTier{
+	{Hours_before: 24, Percent: 90},
+	{Hours_before: 0, Percent: 50},
+}
+
+// ValidateRefundPolicy checks that every tier has a sensible percentage and a unique cut-off
+func ValidateRefundPolicy(policy []models.RefundTier) error {
+	if len(policy) == 0 {
+		return fmt.Errorf("a refund policy needs at least one tier")
+	}
+
+	seen := make(map[int]bool)
+	for _, tier := range policy {
+		if tier.Hours_before < 0 {
+			return fmt.Errorf("hours_before cannot be negative")
+		}
+		if tier.Percent < 0 || tier.Percent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100")
+		}
+		if seen[tier.Hours_before] {
+			return fmt.Errorf("hours_before %d is used by more than one tier", tier.Hours_before)
+		}
+		seen[tier.Hours_before] = true
+	}
+	return nil
+}
+
+// RefundPercent returns the percentage refunded by the policy when cancelling
+// the given time before departure. Cancelling before the smallest cut-off of
+// the policy refunds nothing.
+func RefundPercent(policy []models.RefundTier, beforeDeparture time.Duration) int {
+	tiers := append([]models.RefundTier(nil), policy...)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Hours_before > tiers[j].Hours_before
+	})
+
+	for _, tier := range tiers {
+		if beforeDeparture >= time.Duration(tier.Hours_before)*time.Hour {
+			return tier.Percent
+		}
+	}
+	return 0
+}
+
+// RefundAmount works out the refund for the amount paid, rounded to cents
+func RefundAmount(policy []models.RefundTier, amount float64, beforeDeparture time.Duration) float64 {
+	refund := amount * float64(RefundPercent(policy, beforeDeparture)) / 100
+	return math.Round(refund*100) / 100
+}
+
+// RouteRefundPolicy returns the refund policy of a route, or the default policy
+func RouteRefundPolicy(route *models.Route) []models.RefundTier {
+	if route == nil || len(route.Refund_policy) == 0 {
+		return DefaultRefundPolicy
+	}
+	return route.Refund_policy
+}
+
+// UpdateRouteRefundPolicy sets the refund policy of a route
+func UpdateRouteRefundPolicy(ctx context.Context, routeID string, policy []models.RefundTier) error {
+	update := bson.M{"$set": bson.M{"refund_policy": policy, "updated_at": time.Now()}}
+	result, err := routeCollection.UpdateOne(ctx, bson.M{"route_id": routeID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("no route found with the route_id: %s", routeID)
+	}
+	return nil
+}
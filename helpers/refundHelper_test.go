@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"testing"
+	"time"
+)
+
+func TestRefundPercent(t *testing.T) {
+	policy := []models.RefundTier{
+		{Hours_before: 4, Percent: 25},
+		{Hours_before: 48, Percent: 100},
+		{Hours_before: 12, Percent: 50},
+	}
+
+	tests := []struct {
+		name            string
+		policy          []models.RefundTier
+		beforeDeparture time.Duration
+		want            int
+	}{
+		{"long before departure", policy, 72 * time.Hour, 100},
+		{"exactly at a cut-off", policy, 48 * time.Hour, 100},
+		{"just after a cut-off", policy, 48*time.Hour - time.Minute, 50},
+		{"between cut-offs", policy, 6 * time.Hour, 25},
+		{"after the smallest cut-off", policy, 3 * time.Hour, 0},
+		{"default policy a day ahead", DefaultRefundPolicy, 24 * time.Hour, 90},
+		{"default policy close to departure", DefaultRefundPolicy, time.Hour, 50},
+		{"default policy after departure", DefaultRefundPolicy, -time.Minute, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := RefundPercent(test.policy, test.beforeDeparture); got != test.want {
+				t.Errorf("RefundPercent() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRefundAmount(t *testing.T) {
+	policy := []models.RefundTier{{Hours_before: 0, Percent: 33}}
+	if got := RefundAmount(policy, 99.99, time.Hour); got != 33 {
+		t.Errorf("RefundAmount() = %v, want 33", got)
+	}
+}
+
+func TestValidateRefundPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  []models.RefundTier
+		wantErr bool
+	}{
+		{"valid policy", []models.RefundTier{{Hours_before: 24, Percent: 90}, {Hours_before: 0, Percent: 50}}, false},
+		{"no tiers", nil, true},
+		{"negative cut-off", []models.RefundTier{{Hours_before: -1, Percent: 50}}, true},
+		{"percent above 100", []models.RefundTier{{Hours_before: 0, Percent: 110}}, true},
+		{"duplicate cut-off", []models.RefundTier{{Hours_before: 6, Percent: 50}, {Hours_before: 6, Percent: 20}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ValidateRefundPolicy(test.policy); (err != nil) != test.wantErr {
+				t.Errorf("ValidateRefundPolicy() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
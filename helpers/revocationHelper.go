@@ -0,0 +1,108 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokenCollection *mongo.Collection = configs.GetCollection(configs.DB, "revoked_token")
+
+// RevokeToken revokes a single access token until it expires
+func RevokeToken(ctx context.Context, jti string, userID string, expiresAt time.Time) error {
+	_, err := revokedTokenCollection.UpdateOne(ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			Jti:        jti,
+			User_id:    userID,
+			Expires_at: expiresAt,
+			Created_at: time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RevokeAllUserTokens revokes every access and refresh token issued to a user so far
+func RevokeAllUserTokens(ctx context.Context, userID string) error {
+	now := time.Now()
+	_, err := revokedTokenCollection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "jti": bson.M{"$exists": false}, "family": bson.M{"$exists": false}},
+		bson.M{
+			"$set":         bson.M{"revoked_before": now.UnixMilli()},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = refreshTokenCollection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "status": bson.M{"$ne": models.RefreshRevoked}},
+		bson.M{"$set": bson.M{"status": models.RefreshRevoked}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return endSession(ctx, bson.M{"user_id": userID})
+}
+
+// revokeUserTokensByEmail revokes every token of the user with the given email
+func revokeUserTokensByEmail(ctx context.Context, email string) error {
+	user, err := GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+	return RevokeAllUserTokens(ctx, user.UserID)
+}
+
+// IsTokenRevoked reports whether an access token was revoked on its own,
+// with its session or together with every other token of its user
+func IsTokenRevoked(ctx context.Context, claims *SignedDetails) (bool, error) {
+	conditions := bson.A{
+		bson.M{"user_id": claims.Uid, "revoked_before": bson.M{"$gt": claims.Issued}},
+	}
+	if claims.Id != "" {
+		conditions = append(conditions, bson.M{"jti": claims.Id})
+	}
+	if claims.Family != "" {
+		conditions = append(conditions, bson.M{"family": claims.Family})
+	}
+	count, err := revokedTokenCollection.CountDocuments(ctx, bson.M{"$or": conditions}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteExpiredRevocations forgets revoked access tokens that expired anyway
+func DeleteExpiredRevocations(ctx context.Context) error {
+	_, err := revokedTokenCollection.DeleteMany(ctx, bson.M{
+		"revoked_before": bson.M{"$exists": false},
+		"expires_at":     bson.M{"$lt": time.Now()},
+	})
+	return err
+}
+
+// StartRevocationSweeper forgets expired revoked access tokens in the background at every interval
+func StartRevocationSweeper(interval time.Duration) {
+	go func() {
+		for {
+			if err := DeleteExpiredRevocations(context.Background()); err != nil {
+				log.Println("Error deleting expired token revocations:", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
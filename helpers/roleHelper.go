@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var roleChangeCollection *mongo.Collection = configs.GetCollection(configs.DB, "role_change")
+
+// ErrRoleAboveAssigner is returned when a role above the role of the assigner
+// is given, or the user to change already has such a role
+var ErrRoleAboveAssigner = errors.New("cannot manage a role above your own")
+
+// ErrOwnRole is returned when users try to change their own role
+var ErrOwnRole = errors.New("cannot change your own role")
+
+// ErrRoleChanged is returned when the role of the user changed while it was being assigned
+var ErrRoleChanged = errors.New("role was changed by someone else")
+
+// RecordRoleChange stores the audit record of a role about to be given to a
+// user as pending. Call FinishRoleChange once the role was given or not.
+func RecordRoleChange(ctx context.Context, change models.RoleChange) (*models.RoleChange, error) {
+	change.ID = primitive.NewObjectID()
+	change.Status = models.RoleChangePending
+	change.Created_at = time.Now()
+	if _, err := roleChangeCollection.InsertOne(ctx, change); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// FinishRoleChange records whether a pending role change was applied or failed
+func FinishRoleChange(ctx context.Context, change *models.RoleChange, status string) {
+	_, err := roleChangeCollection.UpdateOne(ctx,
+		bson.M{"_id": change.ID, "status": models.RoleChangePending},
+		bson.M{"$set": bson.M{"status": status}},
+	)
+	if err != nil {
+		log.Println("Error finishing role change", change.ID.Hex(), "of user", change.User_id, err)
+	}
+}
+
+// AssignRole gives a user a new role on behalf of change.By, who has the role
+// change.By_role, and records the change. Assigners can neither give a role
+// above their own nor change users ranked above them. The tokens of the user
+// carry the old role, so they are all revoked and the user logs in again. It
+// returns the updated user, or nil when the user does not exist.
+func AssignRole(ctx context.Context, userID string, change models.RoleChange) (*models.User, error) {
+	if userID == change.By {
+		return nil, ErrOwnRole
+	}
+	if !CanAssignRole(change.By_role, change.To_role) {
+		return nil, ErrRoleAboveAssigner
+	}
+
+	user, err := GetUserByUid(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, nil
+	}
+	change.User_id = userID
+	change.From_role = UserRole(user)
+	if !CanAssignRole(change.By_role, change.From_role) {
+		return nil, ErrRoleAboveAssigner
+	}
+	if change.From_role == change.To_role {
+		return user, nil
+	}
+
+	// The change is audited before it is made
+	record, err := RecordRoleChange(ctx, change)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only change the role if nobody changed it in the meantime
+	filter := bson.M{"user_id": userID, "role": user.Role}
+	if user.Role == "" {
+		filter["role"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+	update := bson.M{"$set": bson.M{"role": change.To_role, "updated_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.User
+	err = userCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err != nil {
+		FinishRoleChange(ctx, record, models.RoleChangeFailed)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRoleChanged
+		}
+		return nil, err
+	}
+	FinishRoleChange(ctx, record, models.RoleChangeApplied)
+
+	if err := RevokeAllUserTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// GetRoleChanges returns the role changes of a user, most recent first
+func GetRoleChanges(ctx context.Context, userID string) ([]models.RoleChange, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := roleChangeCollection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []models.RoleChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,200 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var routeCollection *mongo.Collection = configs.GetCollection(configs.DB, "route")
+
+// ErrRouteNotFound is returned when deleting a route that does not exist
+var ErrRouteNotFound = errors.New("route not found")
+
+// ErrRouteInUse is returned when deleting a route bus trips still use
+var ErrRouteInUse = errors.New("route is still used by bus trips")
+
+// ErrInvalidSegment is returned when a trip does not stop in the from city before the to city
+var ErrInvalidSegment = errors.New("the trip does not travel between these cities")
+
+// ValidateRoute checks that a route has at least two named stops in known time
+// zones whose offsets only move forward in time
+func ValidateRoute(route models.Route) error {
+	if route.Name == "" {
+		return fmt.Errorf("route name is required")
+	}
+	if len(route.Stops) < 2 {
+		return fmt.Errorf("a route needs at least two stops")
+	}
+
+	previousDeparture := 0
+	for i, stop := range route.Stops {
+		if stop.Name == "" || stop.City == "" || stop.Timezone == "" {
+			return fmt.Errorf("stop %d needs a name, a city and a timezone", i+1)
+		}
+		if _, err := StopLocation(stop); err != nil {
+			return err
+		}
+		if stop.Latitude < -90 || stop.Latitude > 90 || stop.Longitude < -180 || stop.Longitude > 180 {
+			return fmt.Errorf("stop %s has invalid coordinates", stop.Name)
+		}
+		if i == 0 && stop.Arrival_offset != 0 {
+			return fmt.Errorf("the first stop must have an arrival offset of 0")
+		}
+		if stop.Arrival_offset < previousDeparture {
+			return fmt.Errorf("stop %s arrives before the previous stop departs", stop.Name)
+		}
+		if stop.Departure_offset < stop.Arrival_offset {
+			return fmt.Errorf("stop %s departs before it arrives", stop.Name)
+		}
+		previousDeparture = stop.Departure_offset
+	}
+	return nil
+}
+
+// GetRouteByRouteId retrieves a route by route_id
+func GetRouteByRouteId(ctx context.Context, routeID string) (*models.Route, error) {
+	var route models.Route
+	err := routeCollection.FindOne(ctx, bson.M{"route_id": routeID}).Decode(&route)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Route not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// InsertRoute stores a new route
+func InsertRoute(ctx context.Context, route models.Route) error {
+	_, err := routeCollection.InsertOne(ctx, route)
+	return err
+}
+
+// GetAllRoutesFromDatabase retrieves all routes from the database
+func GetAllRoutesFromDatabase(ctx context.Context) ([]models.Route, error) {
+	var routes []models.Route
+	cursor, err := routeCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &routes)
+	if err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// UpdateRouteByRouteId replaces the name and stops of a route
+func UpdateRouteByRouteId(ctx context.Context, routeID string, route models.Route) error {
+	update := bson.M{
+		"$set": bson.M{
+			"name":        route.Name,
+			"origin":      route.Origin,
+			"destination": route.Destination,
+			"stops":       route.Stops,
+			"updated_at":  time.Now(),
+		},
+	}
+
+	result, err := routeCollection.UpdateOne(ctx, bson.M{"route_id": routeID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("no route found with the route_id: %s", routeID)
+	}
+
+	// Keep the denormalized origin and destination on the trips of this route in sync
+	_, err = busCollection.UpdateMany(ctx, bson.M{"route_id": routeID}, bson.M{
+		"$set": bson.M{"origin": route.Origin, "destination": route.Destination},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Trips keep departing at the same instant, so their arrival and their
+	// local date and time at the first stop follow the new stops
+	set := bson.M{
+		"arrival_at": bson.M{"$add": bson.A{"$departure_at", int64(route.Stops[len(route.Stops)-1].Arrival_offset) * 60 * 1000}},
+	}
+	if timezone := route.Stops[0].Timezone; timezone != "" {
+		set["date"] = bson.M{"$dateToString": bson.M{"date": "$departure_at", "format": "%Y-%m-%d", "timezone": timezone}}
+		set["departure_time"] = bson.M{"$dateToString": bson.M{"date": "$departure_at", "format": "%H:%M", "timezone": timezone}}
+	}
+	_, err = busCollection.UpdateMany(ctx,
+		bson.M{"route_id": routeID, "departure_at": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: set}}},
+	)
+	return err
+}
+
+// DeleteRouteByRouteId deletes a route that no bus trip uses anymore. A trip
+// added while the route is being deleted puts the route back.
+func DeleteRouteByRouteId(ctx context.Context, routeID string) error {
+	count, err := busCollection.CountDocuments(ctx, bson.M{"route_id": routeID})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRouteInUse
+	}
+
+	var route models.Route
+	err = routeCollection.FindOneAndDelete(ctx, bson.M{"route_id": routeID}).Decode(&route)
+	if err == mongo.ErrNoDocuments {
+		return ErrRouteNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	count, err = busCollection.CountDocuments(ctx, bson.M{"route_id": routeID})
+	if err == nil && count == 0 {
+		return nil
+	}
+	if _, restoreErr := routeCollection.InsertOne(ctx, route); restoreErr != nil {
+		return fmt.Errorf("route %s was deleted while a trip may use it, restoring it failed: %v", routeID, restoreErr)
+	}
+	if err != nil {
+		return err
+	}
+	return ErrRouteInUse
+}
+
+// ResolveSegment checks that a bus travels from one city to another along its
+// route. Empty cities default to the origin and destination of the bus.
+func ResolveSegment(ctx context.Context, bus *models.Bus, from string, to string) (string, string, error) {
+	if from == "" {
+		from = bus.Origin
+	}
+	if to == "" {
+		to = bus.Destination
+	}
+
+	route, err := GetRouteByRouteId(ctx, bus.Route_id)
+	if err != nil {
+		return "", "", err
+	}
+	if route == nil {
+		// Trips created before routes existed only run end to end
+		if from == bus.Origin && to == bus.Destination {
+			return from, to, nil
+		}
+		return "", "", ErrInvalidSegment
+	}
+
+	fromIndex, toIndex := stopIndex(*route, from), stopIndex(*route, to)
+	if fromIndex < 0 || toIndex <= fromIndex {
+		return "", "", ErrInvalidSegment
+	}
+	return route.Stops[fromIndex].City, route.Stops[toIndex].City, nil
+}
@@ -0,0 +1,225 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var scheduleCollection *mongo.Collection = configs.GetCollection(configs.DB, "schedule")
+
+// DefaultHorizonDays is how far ahead trips are generated when a schedule does not say
+const DefaultHorizonDays = 60
+
+// ValidateSchedule checks that a schedule template can generate trips
+func ValidateSchedule(schedule models.Schedule) error {
+	if schedule.Route_id == "" {
+		return fmt.Errorf("route_id is required")
+	}
+	if len(schedule.Weekdays) == 0 {
+		return fmt.Errorf("at least one weekday is required")
+	}
+	for _, weekday := range schedule.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return fmt.Errorf("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if _, err := time.Parse(ClockFormat, schedule.Departure_time); err != nil {
+		return fmt.Errorf("departure_time is required in HH:MM format")
+	}
+	for _, date := range schedule.Blackout_dates {
+		if _, err := time.Parse(DateFormat, date); err != nil {
+			return fmt.Errorf("blackout date %s must be in YYYY-MM-DD format", date)
+		}
+	}
+	if schedule.Horizon_days < 0 || schedule.Horizon_days > 365 {
+		return fmt.Errorf("horizon_days must be between 0 (default of %d) and 365", DefaultHorizonDays)
+	}
+	return nil
+}
+
+// NewSchedule builds a new active schedule template on a route, making sure
+// it builds a valid trip
+func NewSchedule(route *models.Route, request models.Schedule) (models.Schedule, error) {
+	_, err := NewBus(route, models.Bus{
+		Date:           time.Now().Format(DateFormat),
+		Departure_time: request.Departure_time,
+		Fare:           request.Fare,
+		SeatsTotal:     request.SeatsTotal,
+		Layout:         request.Layout,
+	})
+	if err != nil {
+		return models.Schedule{}, err
+	}
+
+	schedule := request
+	schedule.ID = primitive.NewObjectID()
+	schedule.Schedule_id = schedule.ID.Hex()
+	schedule.Route_id = route.Route_id
+	schedule.Active = true
+	schedule.Created_at = time.Now()
+	schedule.Updated_at = time.Now()
+	return schedule, nil
+}
+
+// InsertSchedule stores a new schedule template
+func InsertSchedule(ctx context.Context, schedule models.Schedule) error {
+	_, err := scheduleCollection.InsertOne(ctx, schedule)
+	return err
+}
+
+// GetScheduleByScheduleId retrieves a schedule by schedule_id
+func GetScheduleByScheduleId(ctx context.Context, scheduleID string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	err := scheduleCollection.FindOne(ctx, bson.M{"schedule_id": scheduleID}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Schedule not found
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetAllSchedulesFromDatabase retrieves all schedule templates from the database
+func GetAllSchedulesFromDatabase(ctx context.Context) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	cursor, err := scheduleCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &schedules)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// GenerateTripsForSchedule creates the bus trips of a schedule for every
+// matching day from the given day up to the schedule horizon. Dates that are
+// blacked out or already have a trip at the same time on the route are skipped.
+func GenerateTripsForSchedule(ctx context.Context, schedule models.Schedule, from time.Time) (int, error) {
+	route, err := GetRouteByRouteId(ctx, schedule.Route_id)
+	if err != nil {
+		return 0, err
+	}
+	if route == nil {
+		return 0, fmt.Errorf("route %s of schedule %s not found", schedule.Route_id, schedule.Schedule_id)
+	}
+
+	// Schedules run on the days and at the time local to the first stop
+	location, err := RouteLocation(route)
+	if err != nil {
+		return 0, err
+	}
+	from = from.In(location)
+
+	horizon := schedule.Horizon_days
+	if horizon == 0 {
+		horizon = DefaultHorizonDays
+	}
+
+	weekdays := make(map[time.Weekday]bool)
+	for _, weekday := range schedule.Weekdays {
+		weekdays[time.Weekday(weekday)] = true
+	}
+	blackout := make(map[string]bool)
+	for _, date := range schedule.Blackout_dates {
+		blackout[date] = true
+	}
+
+	created := 0
+	for day := 0; day < horizon; day++ {
+		date := from.AddDate(0, 0, day)
+		dateString := date.Format(DateFormat)
+		if !weekdays[date.Weekday()] || blackout[dateString] {
+			continue
+		}
+
+		count, err := busCollection.CountDocuments(ctx, bson.M{
+			"route_id":       schedule.Route_id,
+			"date":           dateString,
+			"departure_time": schedule.Departure_time,
+		})
+		if err != nil {
+			return created, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		bus, err := NewBus(route, models.Bus{
+			Schedule_id:    schedule.Schedule_id,
+			Date:           dateString,
+			Departure_time: schedule.Departure_time,
+			Bus_type:       schedule.Bus_type,
+			Fare:           schedule.Fare,
+			SeatsTotal:     schedule.SeatsTotal,
+			Layout:         schedule.Layout,
+		})
+		if err != nil {
+			return created, err
+		}
+		_, err = busCollection.InsertOne(ctx, bus)
+		if mongo.IsDuplicateKeyError(err) {
+			continue // Generated by another instance in the meantime
+		}
+		if err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// GenerateTripsForAllSchedules runs the trip generator for every active
+// schedule and reports the errors of every schedule that failed
+func GenerateTripsForAllSchedules(ctx context.Context) (int, error) {
+	var schedules []models.Schedule
+	cursor, err := scheduleCollection.Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return 0, err
+	}
+	if err = cursor.All(ctx, &schedules); err != nil {
+		return 0, err
+	}
+
+	// A failing schedule does not keep the other schedules from their trips
+	created := 0
+	var errs []error
+	for _, schedule := range schedules {
+		count, err := GenerateTripsForSchedule(ctx, schedule, time.Now())
+		created += count
+		if err != nil {
+			errs = append(errs, fmt.Errorf("schedule %s: %v", schedule.Schedule_id, err))
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// StartScheduleGenerator generates trips for all active schedules right away
+// and then again at every interval in the background
+func StartScheduleGenerator(interval time.Duration) {
+	go func() {
+		for {
+			created, err := GenerateTripsForAllSchedules(context.Background())
+			if err != nil {
+				log.Println("Error generating scheduled trips:", err)
+			} else if created > 0 {
+				log.Printf("Generated %d scheduled trips\n", created)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
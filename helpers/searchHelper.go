@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sort orders supported by the trip search
+const (
+	SortByDeparture = "departure"
+	SortByPrice     = "price"
+	SortByDuration  = "duration"
+)
+
+// TripSearch holds the criteria of a trip search. Date is a YYYY-MM-DD date
+// and departure windows are minutes after midnight, both local to the
+// boarding stop; a negative window means no bound.
+type TripSearch struct {
+	From          string
+	To            string
+	Date          string
+	Passengers    int
+	Bus_type      string
+	Depart_after  int
+	Depart_before int
+	Sort          string
+}
+
+// stopIndex returns the position of the first stop in the given city, or -1
+func stopIndex(route models.Route, city string) int {
+	for i, stop := range route.Stops {
+		if strings.EqualFold(stop.City, city) {
+			return i
+		}
+	}
+	return -1
+}
+
+// exactMatch builds a case-insensitive regex matching the whole value
+func exactMatch(value string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value) + "$", Options: "i"}
+}
+
+// SearchTrips finds the bus trips leaving From on the given local date that
+// stop in To afterwards and still have room for the requested number of passengers
+func SearchTrips(ctx context.Context, search TripSearch) ([]models.TripResult, error) {
+	// Find the routes that pass through both cities
+	var routes []models.Route
+	cursor, err := routeCollection.Find(ctx, bson.M{
+		"stops.city": bson.M{"$all": bson.A{exactMatch(search.From), exactMatch(search.To)}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &routes); err != nil {
+		return nil, err
+	}
+
+	// Only keep the routes that visit From before To. Date is the local date
+	// at the boarding stop, so each route departs its first stop within its
+	// own window shifted by how long the bus takes to reach the boarding stop.
+	routesById := make(map[string]models.Route)
+	var windows bson.A
+	for _, route := range routes {
+		from, to := stopIndex(route, search.From), stopIndex(route, search.To)
+		if from < 0 || to <= from {
+			continue
+		}
+		location, err := StopLocation(route.Stops[from])
+		if err != nil {
+			return nil, err
+		}
+		start, end, err := LocalDayRange(search.Date, location)
+		if err != nil {
+			return nil, err
+		}
+		offset := time.Duration(route.Stops[from].Departure_offset) * time.Minute
+
+		routesById[route.Route_id] = route
+		windows = append(windows, bson.M{
+			"route_id":     route.Route_id,
+			"departure_at": bson.M{"$gte": start.Add(-offset), "$lt": end.Add(-offset)},
+		})
+	}
+	if len(windows) == 0 {
+		return []models.TripResult{}, nil
+	}
+
+	filter := bson.M{
+		"$or":    windows,
+		"status": bson.M{"$nin": TripUnbookableStatuses},
+		"$expr": bson.M{
+			"$gte": bson.A{bson.M{"$subtract": bson.A{"$seats_total", bson.M{"$add": bson.A{"$seats_booked", "$seats_held"}}}}, search.Passengers},
+		},
+	}
+	if search.Bus_type != "" {
+		filter["bus_type"] = exactMatch(search.Bus_type)
+	}
+
+	var buses []models.Bus
+	cursor, err = busCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &buses); err != nil {
+		return nil, err
+	}
+
+	rules, err := LoadPricingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	results := []models.TripResult{}
+	for i := range buses {
+		bus := &buses[i]
+		route := routesById[bus.Route_id]
+		from, to := route.Stops[stopIndex(route, search.From)], route.Stops[stopIndex(route, search.To)]
+
+		fromLocation, err := StopLocation(from)
+		if err != nil {
+			return nil, err
+		}
+		toLocation, err := StopLocation(to)
+		if err != nil {
+			return nil, err
+		}
+		departureAt := bus.Departure_at.Add(time.Duration(from.Departure_offset) * time.Minute).In(fromLocation)
+		arrivalAt := bus.Departure_at.Add(time.Duration(to.Arrival_offset) * time.Minute).In(toLocation)
+
+		minuteOfDay := departureAt.Hour()*60 + departureAt.Minute()
+		if search.Depart_after >= 0 && minuteOfDay < search.Depart_after {
+			continue
+		}
+		if search.Depart_before >= 0 && minuteOfDay > search.Depart_before {
+			continue
+		}
+
+		price, err := QuotePrice(ctx, rules, bus, from.City, to.City, search.Passengers, now)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, models.TripResult{
+			Bus_id:           bus.Bus_id,
+			Route_id:         bus.Route_id,
+			From:             from.Name,
+			To:               to.Name,
+			Bus_type:         bus.Bus_type,
+			Departure_at:     departureAt,
+			Arrival_at:       arrivalAt,
+			Duration_minutes: to.Arrival_offset - from.Departure_offset,
+			Fare:             price.Per_seat,
+			Total_fare:       price.Total,
+			Price:            price,
+			Seats_available:  bus.SeatsTotal - bus.SeatsBooked - bus.SeatsHeld,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		switch search.Sort {
+		case SortByPrice:
+			return results[i].Fare < results[j].Fare
+		case SortByDuration:
+			return results[i].Duration_minutes < results[j].Duration_minutes
+		default:
+			return results[i].Departure_at.Before(results[j].Departure_at)
+		}
+	})
+
+	return results, nil
+}
@@ -0,0 +1,282 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// seatSubscriberBuffer is how many updates a slow client may fall behind
+// before it is disconnected and has to reconnect for a fresh seat map
+const seatSubscriberBuffer = 16
+
+// seatSubscriber is a client following a bus. It only gets updates once it
+// was sent the full seat map; changes published before that are noted so the
+// seat map is read again.
+type seatSubscriber struct {
+	ready  bool
+	missed bool
+}
+
+// seatBroker fans seat map changes out to the clients following a bus. It
+// remembers the last seat map it saw of every followed bus so it only sends
+// the seats whose status changed.
+type seatBroker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan models.SeatUpdate]*seatSubscriber
+	seats       map[string]map[string]string
+	status      map[string]string
+	// reloading holds the buses this instance is reading to publish, and
+	// whether they changed again since the read started
+	reloading map[string]bool
+}
+
+var seatStream = &seatBroker{
+	subscribers: make(map[string]map[chan models.SeatUpdate]*seatSubscriber),
+	seats:       make(map[string]map[string]string),
+	status:      make(map[string]string),
+	reloading:   make(map[string]bool),
+}
+
+// seatFeedLocal is set when there is no change stream to follow, e.g. on a
+// standalone MongoDB, so seat changes made by this instance are published directly
+var seatFeedLocal atomic.Bool
+
+func seatsAvailable(bus *models.Bus) int {
+	return bus.SeatsTotal - bus.SeatsBooked - bus.SeatsHeld
+}
+
+// SubscribeSeats follows the seat map of a bus. The first update on the
+// returned channel is the full seat map, read after the client started
+// following so no change is lost in between. The channel is closed when the
+// client falls too far behind; call unsubscribe when the client leaves.
+func SubscribeSeats(ctx context.Context, busID string) (<-chan models.SeatUpdate, func(), error) {
+	updates := make(chan models.SeatUpdate, seatSubscriberBuffer)
+	subscriber := &seatSubscriber{}
+
+	seatStream.mu.Lock()
+	if seatStream.subscribers[busID] == nil {
+		seatStream.subscribers[busID] = make(map[chan models.SeatUpdate]*seatSubscriber)
+	}
+	seatStream.subscribers[busID][updates] = subscriber
+	seatStream.mu.Unlock()
+
+	unsubscribe := func() {
+		seatStream.mu.Lock()
+		defer seatStream.mu.Unlock()
+		subscribers := seatStream.subscribers[busID]
+		if subscribers[updates] == nil {
+			return // Already dropped as a slow client
+		}
+		delete(subscribers, updates)
+		close(updates)
+		if len(subscribers) == 0 {
+			delete(seatStream.subscribers, busID)
+			delete(seatStream.seats, busID)
+			delete(seatStream.status, busID)
+		}
+	}
+
+	// Read the seat map until no change was published while reading it
+	for {
+		bus, err := GetBusByBusId(ctx, busID)
+		if err == nil && bus == nil {
+			err = fmt.Errorf("bus %s not found", busID)
+		}
+		if err != nil {
+			unsubscribe()
+			return nil, nil, err
+		}
+
+		seatStream.mu.Lock()
+		if subscriber.missed {
+			subscriber.missed = false
+			seatStream.mu.Unlock()
+			continue
+		}
+		if seatStream.seats[busID] == nil {
+			statuses := make(map[string]string, len(bus.Seats))
+			for _, seat := range bus.Seats {
+				statuses[seat.Seat_no] = seat.Status
+			}
+			seatStream.seats[busID] = statuses
+			seatStream.status[busID] = TripStatus(bus)
+		}
+		updates <- models.SeatUpdate{
+			Bus_id:          busID,
+			Status:          TripStatus(bus),
+			Seats_available: seatsAvailable(bus),
+			Seats:           bus.Seats,
+			At:              time.Now(),
+		}
+		subscriber.ready = true
+		seatStream.mu.Unlock()
+		return updates, unsubscribe, nil
+	}
+}
+
+// publishSeats sends the seats of a bus that changed since the last update
+// to every client following it
+func publishSeats(bus *models.Bus) {
+	seatStream.mu.Lock()
+	defer seatStream.mu.Unlock()
+
+	subscribers := seatStream.subscribers[bus.Bus_id]
+	for _, subscriber := range subscribers {
+		if !subscriber.ready {
+			subscriber.missed = true
+		}
+	}
+
+	statuses := seatStream.seats[bus.Bus_id]
+	if statuses == nil {
+		return // Nobody was sent the seat map yet
+	}
+	changed := []models.Seat{}
+	for _, seat := range bus.Seats {
+		if statuses[seat.Seat_no] != seat.Status {
+			statuses[seat.Seat_no] = seat.Status
+			changed = append(changed, seat)
+		}
+	}
+	status := TripStatus(bus)
+	if len(changed) == 0 && seatStream.status[bus.Bus_id] == status && len(bus.Seats) > 0 {
+		return
+	}
+	seatStream.status[bus.Bus_id] = status
+
+	update := models.SeatUpdate{
+		Bus_id:          bus.Bus_id,
+		Status:          status,
+		Seats_available: seatsAvailable(bus),
+		Seats:           changed,
+		At:              time.Now(),
+	}
+	for updates, subscriber := range subscribers {
+		if !subscriber.ready {
+			continue
+		}
+		select {
+		case updates <- update:
+		default:
+			// Drop the client rather than let it miss changes
+			delete(subscribers, updates)
+			close(updates)
+		}
+	}
+	if len(subscribers) == 0 {
+		delete(seatStream.subscribers, bus.Bus_id)
+		delete(seatStream.seats, bus.Bus_id)
+		delete(seatStream.status, bus.Bus_id)
+	}
+}
+
+// seatsChanged is called after the seats of a bus changed. With a change
+// stream every instance learns about the change from the stream, otherwise
+// this instance publishes it itself. Only one read of a bus runs at a time,
+// and it is repeated when the bus changed again meanwhile, so an older seat
+// map is never published after a newer one.
+func seatsChanged(busID string) {
+	if !seatFeedLocal.Load() {
+		return
+	}
+	seatStream.mu.Lock()
+	defer seatStream.mu.Unlock()
+	if len(seatStream.subscribers[busID]) == 0 {
+		return
+	}
+	if _, running := seatStream.reloading[busID]; running {
+		seatStream.reloading[busID] = true
+		return
+	}
+	seatStream.reloading[busID] = false
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			bus, err := GetBusByBusId(ctx, busID)
+			cancel()
+			if err != nil || bus == nil {
+				log.Println("Error loading bus", busID, "for seat updates:", err)
+			} else {
+				publishSeats(bus)
+			}
+
+			seatStream.mu.Lock()
+			if !seatStream.reloading[busID] {
+				delete(seatStream.reloading, busID)
+				seatStream.mu.Unlock()
+				return
+			}
+			seatStream.reloading[busID] = false
+			seatStream.mu.Unlock()
+		}
+	}()
+}
+
+// StartSeatFeed follows seat changes of all buses through a MongoDB change
+// stream, so clients connected to any instance see changes made by every
+// instance. When the database does not support change streams it falls back
+// to publishing the changes made by this instance only.
+func StartSeatFeed() {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"update", "replace"}}}}},
+		{{Key: "$project", Value: bson.M{
+			"fullDocument.bus_id":       1,
+			"fullDocument.status":       1,
+			"fullDocument.seats":        1,
+			"fullDocument.seats_total":  1,
+			"fullDocument.seats_booked": 1,
+			"fullDocument.seats_held":   1,
+		}}},
+	}
+
+	stream, err := busCollection.Watch(context.Background(), pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		log.Println("Change streams unavailable, seat updates only cover this instance:", err)
+		seatFeedLocal.Store(true)
+		return
+	}
+
+	go func() {
+		for {
+			for stream.Next(context.Background()) {
+				var event struct {
+					FullDocument *models.Bus `bson:"fullDocument"`
+				}
+				if err := stream.Decode(&event); err != nil {
+					log.Println("Error decoding seat change:", err)
+					continue
+				}
+				if event.FullDocument != nil {
+					publishSeats(event.FullDocument)
+				}
+			}
+
+			// Pick the stream up where it broke off
+			log.Println("Seat change stream interrupted:", stream.Err())
+			resumeToken := stream.ResumeToken()
+			stream.Close(context.Background())
+			for {
+				time.Sleep(5 * time.Second)
+				opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+				if resumeToken != nil {
+					opts.SetResumeAfter(resumeToken)
+				}
+				stream, err = busCollection.Watch(context.Background(), pipeline, opts)
+				if err == nil {
+					break
+				}
+				log.Println("Error resuming seat change stream:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var sessionCollection *mongo.Collection = configs.GetCollection(configs.DB, "session")
+
+// sessionTouchInterval is how often the last seen time of a session is
+// written, so busy clients do not write on every request
+const sessionTouchInterval = time.Minute
+
+// ErrSessionNotFound is returned when a user revokes a session that is not theirs or already ended
+var ErrSessionNotFound = errors.New("session not found")
+
+// CreateSession records a new login of a user on a device
+func CreateSession(ctx context.Context, sessionID string, userID string, userAgent string, ip string) error {
+	now := time.Now()
+	_, err := sessionCollection.InsertOne(ctx, models.Session{
+		Session_id: sessionID,
+		User_id:    userID,
+		User_agent: userAgent,
+		Ip:         ip,
+		Issued_at:  now,
+		Last_seen:  now,
+		Expires_at: now.Add(RefreshTokenTTL()),
+	})
+	return err
+}
+
+// TouchSession records that a session was just used, from which address
+func TouchSession(ctx context.Context, sessionID string, ip string) error {
+	now := time.Now()
+	_, err := sessionCollection.UpdateOne(ctx,
+		bson.M{"session_id": sessionID, "last_seen": bson.M{"$lt": now.Add(-sessionTouchInterval)}},
+		bson.M{"$set": bson.M{"last_seen": now, "ip": ip}},
+	)
+	return err
+}
+
+// extendSession keeps a session alive as long as its newest refresh token
+func extendSession(ctx context.Context, sessionID string) error {
+	now := time.Now()
+	_, err := sessionCollection.UpdateOne(ctx,
+		bson.M{"session_id": sessionID},
+		bson.M{"$set": bson.M{"last_seen": now, "expires_at": now.Add(RefreshTokenTTL())}},
+	)
+	return err
+}
+
+// endSession marks the matching sessions as revoked
+func endSession(ctx context.Context, filter bson.M) error {
+	filter["revoked_at"] = bson.M{"$exists": false}
+	_, err := sessionCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	return err
+}
+
+// GetActiveSessions lists the sessions of a user that are still signed in,
+// most recently used first, flagging the session making the request
+func GetActiveSessions(ctx context.Context, userID string, currentID string) ([]models.Session, error) {
+	sessions := []models.Session{}
+	filter := bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	cursor, err := sessionCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"last_seen": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Session_id == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs a user out of one of their sessions
+func RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	count, err := sessionCollection.CountDocuments(ctx, bson.M{
+		"session_id": sessionID,
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionNotFound
+	}
+	return RevokeTokenFamily(ctx, sessionID)
+}
+
+// RevokeOtherSessions signs a user out of every session but the current one
+// and returns how many sessions were ended
+func RevokeOtherSessions(ctx context.Context, userID string, currentID string) (int, error) {
+	sessions, err := GetActiveSessions(ctx, userID, currentID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.Current {
+			continue
+		}
+		if err := RevokeTokenFamily(ctx, session.Session_id); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
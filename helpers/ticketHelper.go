@@ -0,0 +1,185 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// TicketPayload is what an e-ticket QR code carries. It is signed so
+// conductors can check it offline with the published public key. Times are
+// unix seconds; a ticket stops being valid some time after its trip arrives.
+type TicketPayload struct {
+	Booking_id   string   `json:"booking_id"`
+	Bus_id       string   `json:"bus_id"`
+	Seats        []string `json:"seats"`
+	Passenger    string   `json:"passenger"`
+	Departure_at int64    `json:"departure_at"`
+	Valid_until  int64    `json:"valid_until"`
+	Issued_at    int64    `json:"issued_at"`
+}
+
+// TicketGracePeriod is how long after the arrival of its trip a ticket is
+// still accepted, to cover delays
+const TicketGracePeriod = 12 * time.Hour
+
+// ErrTicketExpired is returned when verifying a ticket past its validity
+var ErrTicketExpired = errors.New("ticket has expired")
+
+// ticketKey signs e-tickets, see SetupTicketKey
+var ticketKey ed25519.PrivateKey
+
+// SetupTicketKey loads the key tickets are signed with from
+// TICKET_SIGNING_KEY, a base64 ed25519 seed. The key is required: every
+// instance must sign with the same key, and it must survive restarts, or the
+// published public key would stop matching the tickets already issued.
+func SetupTicketKey() {
+	seed := os.Getenv("TICKET_SIGNING_KEY")
+	if seed == "" {
+		log.Fatal("TICKET_SIGNING_KEY must be set")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(decoded) != ed25519.SeedSize {
+		log.Fatal("TICKET_SIGNING_KEY must be a base64 encoded 32 byte ed25519 seed")
+	}
+	ticketKey = ed25519.NewKeyFromSeed(decoded)
+}
+
+// TicketPublicKey returns the base64 encoded public key tickets can be verified with
+func TicketPublicKey() string {
+	return base64.StdEncoding.EncodeToString(ticketKey.Public().(ed25519.PublicKey))
+}
+
+// SignTicket encodes the payload and its signature as "<payload>.<signature>", both base64url
+func SignTicket(payload TicketPayload) (string, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signature := ed25519.Sign(ticketKey, encoded)
+	return base64.RawURLEncoding.EncodeToString(encoded) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyTicket checks a signed ticket against a base64 encoded public key and
+// returns its payload, or ErrTicketExpired with the payload when the ticket
+// is no longer valid at the given time. It needs no database, so it works offline.
+func VerifyTicket(ticket string, publicKey string, now time.Time) (*TicketPayload, error) {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	parts := strings.Split(ticket, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid ticket format")
+	}
+	encoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket payload")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), encoded, signature) {
+		return nil, fmt.Errorf("ticket signature does not match")
+	}
+
+	var payload TicketPayload
+	if err := json.Unmarshal(encoded, &payload); err != nil {
+		return nil, fmt.Errorf("invalid ticket payload")
+	}
+	if now.Unix() > payload.Valid_until {
+		return &payload, ErrTicketExpired
+	}
+	return &payload, nil
+}
+
+// NewTicketPayload builds the ticket payload of a booking on a bus. The
+// ticket is valid until the grace period after the trip arrives.
+func NewTicketPayload(booking *models.Booking, bus *models.Bus, passenger string) (TicketPayload, error) {
+	departure, err := TripDeparture(bus)
+	if err != nil {
+		return TicketPayload{}, err
+	}
+	arrival := bus.Arrival_at
+	if arrival.Before(departure) {
+		arrival = departure
+	}
+
+	seats := booking.Seat_numbers
+	if len(seats) == 0 {
+		seats = []string{fmt.Sprintf("%d seat(s)", booking.Seats)}
+	}
+	return TicketPayload{
+		Booking_id:   booking.Booking_id,
+		Bus_id:       booking.Bus_id,
+		Seats:        seats,
+		Passenger:    passenger,
+		Departure_at: departure.Unix(),
+		Valid_until:  arrival.Add(TicketGracePeriod).Unix(),
+		Issued_at:    time.Now().Unix(),
+	}, nil
+}
+
+// TicketQRCode renders a signed ticket as a PNG QR code
+func TicketQRCode(ticket string, size int) ([]byte, error) {
+	return qrcode.Encode(ticket, qrcode.Medium, size)
+}
+
+// TicketPDF renders a printable ticket of a booking with its QR code
+func TicketPDF(booking *models.Booking, bus *models.Bus, passenger string, ticket string) ([]byte, error) {
+	png, err := TicketQRCode(ticket, 512)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.Cell(0, 12, "Bus e-ticket")
+	pdf.Ln(16)
+
+	seats := strings.Join(booking.Seat_numbers, ", ")
+	if seats == "" {
+		seats = fmt.Sprintf("%d seat(s)", booking.Seats)
+	}
+	lines := [][2]string{
+		{"Booking", booking.Booking_id},
+		{"Passenger", passenger},
+		{"Bus", bus.Bus_id},
+		{"From", booking.From},
+		{"To", booking.To},
+		{"Date", bus.Date + " " + bus.Departure_time},
+		{"Seats", seats},
+		{"Amount paid", fmt.Sprintf("%.2f %s", booking.Amount, PaymentCurrency())},
+	}
+	for _, line := range lines {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.Cell(40, 8, line[0])
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.Cell(0, 8, line[1])
+		pdf.Ln(8)
+	}
+
+	pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+	pdf.ImageOptions("qr", 10, pdf.GetY()+6, 70, 70, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
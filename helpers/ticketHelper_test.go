@@ -0,0 +1,67 @@
+package helpers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyTicket(t *testing.T) {
+	ticketKey = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	publicKey := TicketPublicKey()
+	otherKey := base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize)).Public().(ed25519.PublicKey))
+
+	validUntil := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	ticket, err := SignTicket(TicketPayload{
+		Booking_id:  "booking-1",
+		Bus_id:      "bus-1",
+		Seats:       []string{"1A"},
+		Passenger:   "Asha",
+		Valid_until: validUntil.Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(ticket, ".")
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(`{"booking_id":"booking-2"}`)) + "." + parts[1]
+
+	tests := []struct {
+		name        string
+		ticket      string
+		publicKey   string
+		now         time.Time
+		wantErr     error
+		wantPayload bool
+	}{
+		{"valid ticket", ticket, publicKey, validUntil.Add(-time.Hour), nil, true},
+		{"valid at its last second", ticket, publicKey, validUntil, nil, true},
+		{"expired ticket", ticket, publicKey, validUntil.Add(time.Second), ErrTicketExpired, true},
+		{"tampered payload", tampered, publicKey, validUntil, errors.New("signature"), false},
+		{"signed with another key", ticket, otherKey, validUntil, errors.New("signature"), false},
+		{"invalid public key", ticket, "not a key", validUntil, errors.New("public key"), false},
+		{"missing signature", parts[0], publicKey, validUntil, errors.New("format"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := VerifyTicket(test.ticket, test.publicKey, test.now)
+			switch {
+			case test.wantErr == nil && err != nil:
+				t.Fatalf("VerifyTicket() error = %v", err)
+			case test.wantErr == ErrTicketExpired && !errors.Is(err, ErrTicketExpired):
+				t.Fatalf("VerifyTicket() error = %v, want %v", err, ErrTicketExpired)
+			case test.wantErr != nil && (err == nil || !strings.Contains(err.Error(), test.wantErr.Error())):
+				t.Fatalf("VerifyTicket() error = %v, want one about %q", err, test.wantErr)
+			}
+			if (payload != nil) != test.wantPayload {
+				t.Fatalf("VerifyTicket() payload = %v, want payload %v", payload, test.wantPayload)
+			}
+			if payload != nil && payload.Booking_id != "booking-1" {
+				t.Errorf("booking id = %s, want booking-1", payload.Booking_id)
+			}
+		})
+	}
+}
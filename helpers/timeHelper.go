@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"fmt"
+	"time"
+)
+
+// Formats of the local dates and clock times of trips
+const (
+	DateFormat  = "2006-01-02"
+	ClockFormat = "15:04"
+)
+
+// StopLocation returns the time zone of a stop. Stops created before stops
+// had a time zone use the time zone of the server.
+func StopLocation(stop models.Stop) (*time.Location, error) {
+	if stop.Timezone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(stop.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("stop %s has an unknown timezone %s", stop.Name, stop.Timezone)
+	}
+	return location, nil
+}
+
+// RouteLocation returns the time zone of the first stop of a route, in which
+// the date and departure time of its trips are given
+func RouteLocation(route *models.Route) (*time.Location, error) {
+	if route == nil || len(route.Stops) == 0 {
+		return time.Local, nil
+	}
+	return StopLocation(route.Stops[0])
+}
+
+// ParseLocalDate parses a YYYY-MM-DD date as midnight in the given time zone
+func ParseLocalDate(value string, location *time.Location) (time.Time, error) {
+	date, err := time.ParseInLocation(DateFormat, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q must be a real date in YYYY-MM-DD format", value)
+	}
+	return date, nil
+}
+
+// LocalDeparture combines a YYYY-MM-DD date and an HH:MM clock time at the
+// first stop of a route into the instant the trip departs
+func LocalDeparture(route *models.Route, date string, clock string) (time.Time, error) {
+	location, err := RouteLocation(route)
+	if err != nil {
+		return time.Time{}, err
+	}
+	day, err := ParseLocalDate(date, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at, err := time.Parse(ClockFormat, clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("departure_time %q must be in HH:MM format", clock)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, location), nil
+}
+
+// LocalDayRange returns the instants at which a local date starts and ends in
+// the given time zone, which are not always 24 hours apart
+func LocalDayRange(date string, location *time.Location) (time.Time, time.Time, error) {
+	start, err := ParseLocalDate(date, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, location)
+	return start, end, nil
+}
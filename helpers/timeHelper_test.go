@@ -0,0 +1,50 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalDayRange(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		date      string
+		location  *time.Location
+		wantStart time.Time
+		wantHours float64
+		wantErr   bool
+	}{
+		{"regular day", "2026-10-18", kolkata, time.Date(2026, 10, 17, 18, 30, 0, 0, time.UTC), 24, false},
+		{"clocks go back", "2026-10-25", berlin, time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC), 25, false},
+		{"clocks go forward", "2026-03-29", berlin, time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC), 23, false},
+		{"last day of the year", "2026-12-31", berlin, time.Date(2026, 12, 30, 23, 0, 0, 0, time.UTC), 24, false},
+		{"not a date", "2026-02-30", kolkata, time.Time{}, 0, true},
+		{"wrong format", "18/10/2026", kolkata, time.Time{}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, err := LocalDayRange(test.date, test.location)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("LocalDayRange() error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if !start.Equal(test.wantStart) {
+				t.Errorf("start = %v, want %v", start.UTC(), test.wantStart)
+			}
+			if hours := end.Sub(start).Hours(); hours != test.wantHours {
+				t.Errorf("day lasts %v hours, want %v", hours, test.wantHours)
+			}
+		})
+	}
+}
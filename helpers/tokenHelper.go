@@ -7,20 +7,27 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// SignedDetails
+// SignedDetails are the claims of an access token. Family is the refresh
+// token family the token was issued with and Issued the time it was issued
+// in unix milliseconds, so revoking a user's tokens also covers tokens
+// issued earlier in the same second. The token ID is the standard jti claim.
 type SignedDetails struct {
 	Email    string
 	Username string
 
-	Role string
-	Uid  string
+	Role   string
+	Uid    string
+	Family string
+	Issued int64
 	jwt.StandardClaims
 }
 
@@ -29,30 +36,58 @@ var userCollection *mongo.Collection = configs.GetCollection(configs.DB, "user")
 
 var SECRET_KEY string = os.Getenv("SECRET_KEY")
 
-// GenerateAllTokens generates both teh detailed token and refresh token
-func GenerateAllTokens(email string, username string, uid string, role string) (signedToken string, err error) {
+// AccessTokenTTL is how long an access token is valid, from ACCESS_TOKEN_MINUTES (15 by default)
+func AccessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RefreshTokenTTL is how long a refresh token is valid, from REFRESH_TOKEN_DAYS (30 by default)
+func RefreshTokenTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GenerateAllTokens generates both the short-lived access token and a
+// refresh token starting a new token family
+func GenerateAllTokens(ctx context.Context, email string, username string, uid string, role string) (*models.TokenPair, error) {
+	return generateTokenPair(ctx, email, username, uid, role, primitive.NewObjectID().Hex())
+}
+
+func generateTokenPair(ctx context.Context, email string, username string, uid string, role string, familyID string) (*models.TokenPair, error) {
+	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(AccessTokenTTL())
 	claims := &SignedDetails{
 		Email:    email,
 		Username: username,
 		Role:     role,
 		Uid:      uid,
+		Family:   familyID,
+		Issued:   issuedAt.UnixMilli(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        primitive.NewObjectID().Hex(),
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: expiresAt.Unix(),
 		},
 	}
 
 	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
 	if err != nil {
-		log.Panic(err)
-		return
+		return nil, err
 	}
 
+	refreshToken, err := issueRefreshToken(ctx, uid, familyID)
 	if err != nil {
-		log.Panic(err)
-		return
+		return nil, err
 	}
 
-	return token, err
+	return &models.TokenPair{Token: token, Refresh_token: refreshToken, Expires_at: expiresAt, Session_id: familyID}, nil
 }
 
 // ValidateToken validates the jwt token
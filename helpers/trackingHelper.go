@@ -0,0 +1,180 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var trackCollection *mongo.Collection = configs.GetCollection(configs.DB, "tracking")
+
+// TrackLength is how many of the most recent pings are kept per trip
+const TrackLength = 200
+
+// ErrTripNotRunning is returned when a ping is reported for a cancelled or arrived trip
+var ErrTripNotRunning = errors.New("trip is not running")
+
+// RecordPing adds a position reported by the device on a bus to the track of
+// its trip. Pings may arrive out of order; the track stays sorted by the time
+// they were recorded and only keeps the most recent ones.
+func RecordPing(ctx context.Context, bus *models.Bus, ping models.Ping) error {
+	status := TripStatus(bus)
+	if status == models.TripCancelled || status == models.TripArrived {
+		return ErrTripNotRunning
+	}
+
+	ping.Received_at = time.Now()
+	update := bson.M{
+		"$push": bson.M{"pings": bson.M{
+			"$each":  bson.A{ping},
+			"$sort":  bson.M{"recorded_at": 1},
+			"$slice": -TrackLength,
+		}},
+		"$set":         bson.M{"updated_at": ping.Received_at},
+		"$setOnInsert": bson.M{"bus_id": bus.Bus_id},
+	}
+	_, err := trackCollection.UpdateOne(ctx, bson.M{"bus_id": bus.Bus_id}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetTrack retrieves the recent track of a trip
+func GetTrack(ctx context.Context, busID string) (*models.Track, error) {
+	var track models.Track
+	err := trackCollection.FindOne(ctx, bson.M{"bus_id": busID}).Decode(&track)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Nothing reported yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &track, nil
+}
+
+// distanceKm is the great-circle distance between two coordinates
+func distanceKm(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRadians := math.Pi / 180
+	dLat := (lat2 - lat1) * toRadians
+	dLon := (lon2 - lon1) * toRadians
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRadians)*math.Cos(lat2*toRadians)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func hasCoordinates(stop models.Stop) bool {
+	return stop.Latitude != 0 || stop.Longitude != 0
+}
+
+// observedDelay works out how far along its route a bus is from a ping. The
+// bus is placed on the stretch between two stops with coordinates that it
+// strays least from, and compared with when the timetable has it there. It
+// returns the last stop the bus passed and how late it is, or false when
+// the route has too few stops with coordinates.
+func observedDelay(route *models.Route, departure time.Time, ping models.Ping) (int, time.Duration, bool) {
+	var located []int
+	for i, stop := range route.Stops {
+		if hasCoordinates(stop) {
+			located = append(located, i)
+		}
+	}
+	if len(located) < 2 {
+		return 0, 0, false
+	}
+
+	segment, fraction, best := 0, 0.0, math.Inf(1)
+	for i := 0; i+1 < len(located); i++ {
+		from, to := route.Stops[located[i]], route.Stops[located[i+1]]
+		toFrom := distanceKm(from.Latitude, from.Longitude, ping.Latitude, ping.Longitude)
+		toTo := distanceKm(ping.Latitude, ping.Longitude, to.Latitude, to.Longitude)
+		detour := toFrom + toTo - distanceKm(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+		if detour < best {
+			best, segment = detour, i
+			fraction = 0
+			if toFrom+toTo > 0 {
+				fraction = toFrom / (toFrom + toTo)
+			}
+		}
+	}
+
+	passed, next := located[segment], route.Stops[located[segment+1]]
+	leaves := float64(route.Stops[passed].Departure_offset)
+	minutes := leaves + fraction*(float64(next.Arrival_offset)-leaves)
+	scheduled := departure.Add(time.Duration(minutes * float64(time.Minute)))
+	return passed, ping.Recorded_at.Sub(scheduled), true
+}
+
+// GetTripPosition returns the last reported position of a trip and when it
+// is expected at each stop. Before departure the delay reported with the trip
+// status is used; once the bus is on the road the delay observed from its
+// position is used. Buses never make up time by leaving stops early.
+func GetTripPosition(ctx context.Context, bus *models.Bus) (*models.TripPosition, error) {
+	track, err := GetTrack(ctx, bus.Bus_id)
+	if err != nil {
+		return nil, err
+	}
+	route, err := GetRouteByRouteId(ctx, bus.Route_id)
+	if err != nil {
+		return nil, err
+	}
+	departure, err := TripDeparture(bus)
+	if err != nil {
+		return nil, err
+	}
+
+	position := &models.TripPosition{
+		Bus_id: bus.Bus_id,
+		Status: TripStatus(bus),
+		Stops:  []models.StopEta{},
+	}
+	if track != nil && len(track.Pings) > 0 {
+		position.Position = &track.Pings[len(track.Pings)-1]
+	}
+	if route == nil {
+		return position, nil // Trips created before routes existed have no stops to reach
+	}
+
+	delay := time.Duration(bus.Delay_minutes) * time.Minute
+	passed := -1
+	switch position.Status {
+	case models.TripDeparted:
+		passed = 0
+		if position.Position != nil {
+			if observedPassed, observed, ok := observedDelay(route, departure, *position.Position); ok {
+				passed, delay = observedPassed, observed
+			}
+		}
+	case models.TripArrived:
+		passed = len(route.Stops) - 1
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	position.Delay_minutes = int(delay.Round(time.Minute) / time.Minute)
+
+	for i, stop := range route.Stops {
+		offset := stop.Arrival_offset
+		if i == 0 {
+			offset = stop.Departure_offset
+		}
+		location, err := StopLocation(stop)
+		if err != nil {
+			return nil, err
+		}
+		scheduled := departure.Add(time.Duration(offset) * time.Minute)
+		position.Stops = append(position.Stops, models.StopEta{
+			Name:         stop.Name,
+			City:         stop.City,
+			Scheduled_at: scheduled.In(location),
+			Expected_at:  scheduled.Add(delay).Truncate(time.Minute).In(location),
+			Passed:       i <= passed,
+		})
+	}
+	return position, nil
+}
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidTransition is returned when a trip cannot move to the requested status from its current one
+var ErrInvalidTransition = errors.New("trip cannot move to this status from its current status")
+
+// tripTransitions lists the statuses each trip status may move to. Delayed
+// may repeat to update the expected delay.
+var tripTransitions = map[string][]string{
+	models.TripScheduled: {models.TripDelayed, models.TripBoarding, models.TripCancelled},
+	models.TripDelayed:   {models.TripDelayed, models.TripBoarding, models.TripCancelled},
+	models.TripBoarding:  {models.TripDelayed, models.TripDeparted, models.TripCancelled},
+	models.TripDeparted:  {models.TripArrived},
+	models.TripArrived:   {},
+	models.TripCancelled: {},
+}
+
+// TripStatus returns the status of a trip. Trips created before trips had a
+// status are scheduled.
+func TripStatus(bus *models.Bus) string {
+	if bus.Status == "" {
+		return models.TripScheduled
+	}
+	return bus.Status
+}
+
+// ValidTripStatus reports whether status is a known trip status
+func ValidTripStatus(status string) bool {
+	_, exists := tripTransitions[status]
+	return exists
+}
+
+// CanTransition reports whether a trip may move from one status to another
+func CanTransition(from string, to string) bool {
+	for _, next := range tripTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TripBookable reports whether seats can still be held and booked on a trip
+func TripBookable(bus *models.Bus) bool {
+	switch TripStatus(bus) {
+	case models.TripScheduled, models.TripDelayed, models.TripBoarding:
+		return true
+	}
+	return false
+}
+
+// TripUnbookableStatuses are the statuses of trips that are never offered for booking
+var TripUnbookableStatuses = bson.A{models.TripDeparted, models.TripArrived, models.TripCancelled}
+
+// setTripStatus atomically moves a trip to a new status if its current status
+// allows it, recording the change in its status history. The delay is only
+// kept while the trip is delayed or boarding late.
+func setTripStatus(ctx context.Context, busID string, event models.TripStatusEvent) (*models.Bus, error) {
+	bus, err := GetBusByBusId(ctx, busID)
+	if err != nil {
+		return nil, err
+	}
+	if bus == nil {
+		return nil, nil
+	}
+	event.From = TripStatus(bus)
+	if !CanTransition(event.From, event.Status) {
+		return nil, ErrInvalidTransition
+	}
+	event.At = time.Now()
+
+	set := bson.M{"status": event.Status, "updated_at": event.At}
+	switch event.Status {
+	case models.TripDelayed:
+		set["delay_minutes"] = event.Delay_minutes
+	case models.TripBoarding:
+		// A delayed trip boards late
+	default:
+		set["delay_minutes"] = 0
+	}
+
+	// Only move the trip if nobody moved it to another status in the meantime
+	filter := bson.M{"bus_id": busID, "status": bus.Status}
+	if bus.Status == "" {
+		filter["status"] = bson.M{"$in": bson.A{nil, ""}}
+	}
+	update := bson.M{"$set": set, "$push": bson.M{"status_history": event}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Bus
+	err = busCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidTransition
+	}
+	if err != nil {
+		return nil, err
+	}
+	seatsChanged(busID)
+	return &updated, nil
+}
+
+// TransitionTrip moves a trip to a new status. Cancelling a trip also
+// releases its seats and refunds its bookings, see CancelTrip. It returns the
+// updated trip, or nil when the bus does not exist.
+func TransitionTrip(ctx context.Context, busID string, event models.TripStatusEvent) (*models.Bus, error) {
+	if event.Status == models.TripCancelled {
+		if _, err := CancelTrip(ctx, busID, event); err != nil {
+			return nil, err
+		}
+		return GetBusByBusId(ctx, busID)
+	}
+	return setTripStatus(ctx, busID, event)
+}
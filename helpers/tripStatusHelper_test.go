@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	models "busapp/models"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{models.TripScheduled, models.TripDelayed, true},
+		{models.TripScheduled, models.TripBoarding, true},
+		{models.TripScheduled, models.TripCancelled, true},
+		{models.TripScheduled, models.TripDeparted, false},
+		{models.TripDelayed, models.TripDelayed, true},
+		{models.TripDelayed, models.TripBoarding, true},
+		{models.TripBoarding, models.TripDeparted, true},
+		{models.TripBoarding, models.TripScheduled, false},
+		{models.TripDeparted, models.TripArrived, true},
+		{models.TripDeparted, models.TripCancelled, false},
+		{models.TripArrived, models.TripScheduled, false},
+		{models.TripCancelled, models.TripCancelled, false},
+		{models.TripCancelled, models.TripScheduled, false},
+		{"unknown", models.TripScheduled, false},
+	}
+	for _, test := range tests {
+		if got := CanTransition(test.from, test.to); got != test.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestTripBookable(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{"", true},
+		{models.TripScheduled, true},
+		{models.TripDelayed, true},
+		{models.TripBoarding, true},
+		{models.TripDeparted, false},
+		{models.TripArrived, false},
+		{models.TripCancelled, false},
+	}
+	for _, test := range tests {
+		if got := TripBookable(&models.Bus{Status: test.status}); got != test.want {
+			t.Errorf("TripBookable(%q) = %v, want %v", test.status, got, test.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/rand"
 	"net/smtp"
+	"os"
 	"strconv"
 	"time"
 
@@ -14,6 +15,40 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Mail account every email of the app is sent from, see SetupMail
+var (
+	mailServer   string
+	mailPort     string
+	mailAddress  string
+	mailPassword string
+)
+
+// SetupMail loads the SMTP account emails are sent from: SMTP_USERNAME and
+// SMTP_PASSWORD are required, SMTP_HOST and SMTP_PORT default to Gmail.
+func SetupMail() {
+	mailAddress = os.Getenv("SMTP_USERNAME")
+	mailPassword = os.Getenv("SMTP_PASSWORD")
+	if mailAddress == "" || mailPassword == "" {
+		log.Fatal("SMTP_USERNAME and SMTP_PASSWORD must be set")
+	}
+
+	mailServer = os.Getenv("SMTP_HOST")
+	if mailServer == "" {
+		mailServer = "smtp.gmail.com"
+	}
+	mailPort = os.Getenv("SMTP_PORT")
+	if mailPort == "" {
+		mailPort = "587"
+	}
+}
+
+// sendMail sends a plain text email to a single recipient
+func sendMail(to string, subject string, body string) error {
+	message := []byte("Subject: " + subject + "\n\n" + body)
+	auth := smtp.PlainAuth("", mailAddress, mailPassword, mailServer)
+	return smtp.SendMail(mailServer+":"+mailPort, auth, mailAddress, []string{to}, message)
+}
+
 // HashPassword is used to encrypt the password before it is stored in the DB
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -43,14 +78,7 @@ func SendResetLinkEmail(email, resetToken string) error {
 	// Implement email sending logic here
 	// Example: using a hypothetical email server (replace with your actual email sending code)
 	body := fmt.Sprintf("Click the following link to reset your password: http://yourapp.com/reset-password?token=%s", resetToken)
-	message := []byte("Subject: Password Reset\n\n" + body)
-
-	err := smtp.SendMail("smtp.gmail.com:587", smtp.PlainAuth("", "musthaqmohamed123456@gmail.com", "fsjflbpzbeuiwskl", "smtp.gmail.com"), "musthaqmohamed123456@gmail.com", []string{email}, message)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return sendMail(email, "Password Reset", body)
 }
 
 // UpdateUserPassword updates the user's password in the database
@@ -70,7 +98,8 @@ func UpdateUserPassword(ctx context.Context, email string, hashedPassword string
 
 	fmt.Printf("Matched %v document(s) and modified %v document(s)\n", result.MatchedCount, result.ModifiedCount)
 
-	return nil
+	// Tokens issued with the old password stop working
+	return revokeUserTokensByEmail(ctx, email)
 }
 
 // GenerateOTP generates a random six-digit OTP
@@ -107,14 +136,7 @@ func SendOTPEmail(email string, otp string) error {
 	// Implement email sending logic here
 	// Example: using a hypothetical email server (replace with your actual email sending code)
 	body := fmt.Sprintf("Copy the following otp to reset your password: %s", otp)
-	message := []byte("Subject: Password Reset\n\n" + body)
-
-	err := smtp.SendMail("smtp.gmail.com:587", smtp.PlainAuth("", "musthaqmohamed123456@gmail.com", "fsjflbpzbeuiwskl", "smtp.gmail.com"), "musthaqmohamed123456@gmail.com", []string{email}, message)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return sendMail(email, "Password Reset", body)
 }
 
 // ValidateOTPByEmail validates the provided OTP against the stored OTP in the database
@@ -166,3 +188,22 @@ func ClearOTPByEmail(ctx context.Context, email string) error {
 
 	return nil
 }
+
+// SendWaitlistHoldEmail tells a waitlisted customer that seats are held for them
+func SendWaitlistHoldEmail(email string, hold *models.Hold) error {
+	body := fmt.Sprintf("Good news! %d seat(s) on bus %s are now held for you. Complete your booking with hold id %s before %s.",
+		hold.Seats, hold.Bus_id, hold.Hold_id, hold.Expires_at.Format(time.RFC1123))
+	return sendMail(email, "Seats available from the waitlist", body)
+}
+
+// SendTripCancelledEmail tells a customer their trip was cancelled and what
+// is refunded, or that an unpaid booking will not be charged
+func SendTripCancelledEmail(email string, bus *models.Bus, booking *models.Booking) error {
+	outcome := fmt.Sprintf("Booking %s has been refunded %.2f.", booking.Booking_id, booking.Refund_amount)
+	if booking.Status == models.BookingFailed {
+		outcome = fmt.Sprintf("Booking %s was not completed and will not be charged.", booking.Booking_id)
+	}
+	body := fmt.Sprintf("We are sorry, your trip from %s to %s on %s %s was cancelled. %s",
+		booking.From, booking.To, bus.Date, bus.Departure_time, outcome)
+	return sendMail(email, "Your trip was cancelled", body)
+}
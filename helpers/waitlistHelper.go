@@ -0,0 +1,170 @@
+package helpers
+
+import (
+	configs "busapp/database"
+	models "busapp/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var waitlistCollection *mongo.Collection = configs.GetCollection(configs.DB, "waitlist")
+
+// ErrSeatsAvailable is returned when joining the waitlist of a bus that still has room
+var ErrSeatsAvailable = errors.New("seats are still available on this bus")
+
+// ErrAlreadyWaitlisted is returned when the user is already waiting for the bus
+var ErrAlreadyWaitlisted = errors.New("already on the waitlist for this bus")
+
+// waitlistOrder sorts waitlist entries first come, first served
+var waitlistOrder = bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}
+
+// JoinWaitlist queues the user for seats on a bus that cannot fit them anymore
+func JoinWaitlist(ctx context.Context, bus *models.Bus, userID string, email string, seats int, from string, to string) (*models.WaitlistEntry, error) {
+	if !TripBookable(bus) {
+		return nil, ErrTripNotBookable
+	}
+	if bus.SeatsTotal-bus.SeatsBooked-bus.SeatsHeld >= seats {
+		return nil, ErrSeatsAvailable
+	}
+	from, to, err := ResolveSegment(ctx, bus, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.WaitlistEntry{
+		ID:         primitive.NewObjectID(),
+		Bus_id:     bus.Bus_id,
+		User_id:    userID,
+		Email:      email,
+		Seats:      seats,
+		From:       from,
+		To:         to,
+		Status:     models.WaitlistWaiting,
+		Created_at: time.Now(),
+		Updated_at: time.Now(),
+	}
+	entry.Waitlist_id = entry.ID.Hex()
+
+	// Only insert the entry when the user is not waiting for the bus yet
+	result, err := waitlistCollection.UpdateOne(ctx,
+		bson.M{"bus_id": bus.Bus_id, "user_id": userID, "status": models.WaitlistWaiting},
+		bson.M{"$setOnInsert": entry},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrAlreadyWaitlisted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.UpsertedCount == 0 {
+		return nil, ErrAlreadyWaitlisted
+	}
+	return &entry, nil
+}
+
+// GetWaitlistEntry retrieves the latest waitlist entry of the user for a bus
+func GetWaitlistEntry(ctx context.Context, busID string, userID string) (*models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := waitlistCollection.FindOne(ctx, bson.M{"bus_id": busID, "user_id": userID}, opts).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil // Not on the waitlist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// WaitlistPosition returns the 1-based position of a waiting entry in its bus queue
+func WaitlistPosition(ctx context.Context, entry *models.WaitlistEntry) (int, error) {
+	ahead, err := waitlistCollection.CountDocuments(ctx, bson.M{
+		"bus_id": entry.Bus_id,
+		"status": models.WaitlistWaiting,
+		"$or": bson.A{
+			bson.M{"created_at": bson.M{"$lt": entry.Created_at}},
+			bson.M{"created_at": entry.Created_at, "_id": bson.M{"$lt": entry.ID}},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(ahead) + 1, nil
+}
+
+// PromoteWaitlist hands freed seats on a bus to the waitlist in order. Every
+// promoted customer gets a time-limited hold and an email about it. Promotion
+// stops at the first customer whose seats do not fit, so nobody is skipped.
+func PromoteWaitlist(ctx context.Context, busID string) (int, error) {
+	promoted := 0
+	for {
+		// Claim the first waiting customer so no other instance promotes them too
+		var entry models.WaitlistEntry
+		opts := options.FindOneAndUpdate().SetSort(waitlistOrder).SetReturnDocument(options.After)
+		err := waitlistCollection.FindOneAndUpdate(ctx,
+			bson.M{"bus_id": busID, "status": models.WaitlistWaiting},
+			bson.M{"$set": bson.M{"status": models.WaitlistPromoted, "updated_at": time.Now()}},
+			opts,
+		).Decode(&entry)
+		if err == mongo.ErrNoDocuments {
+			return promoted, nil
+		}
+		if err != nil {
+			return promoted, err
+		}
+
+		bus, err := GetBusByBusId(ctx, busID)
+		if err == nil && bus == nil {
+			err = errors.New("bus not found")
+		}
+		var hold *models.Hold
+		if err == nil {
+			hold, err = CreateHold(ctx, bus, entry.User_id, HoldRequest{Seats: entry.Seats, From: entry.From, To: entry.To})
+		}
+		if err != nil {
+			// Put the customer back in the queue at the same position
+			_, revertErr := waitlistCollection.UpdateOne(ctx,
+				bson.M{"waitlist_id": entry.Waitlist_id},
+				bson.M{"$set": bson.M{"status": models.WaitlistWaiting}},
+			)
+			if revertErr != nil {
+				return promoted, revertErr
+			}
+			if err == ErrNotEnoughSeats {
+				return promoted, nil
+			}
+			return promoted, err
+		}
+
+		_, err = waitlistCollection.UpdateOne(ctx,
+			bson.M{"waitlist_id": entry.Waitlist_id},
+			bson.M{"$set": bson.M{"hold_id": hold.Hold_id}},
+		)
+		if err != nil {
+			return promoted, err
+		}
+		promoted++
+
+		if err := SendWaitlistHoldEmail(entry.Email, hold); err != nil {
+			log.Println("Error sending waitlist email to", entry.Email, err)
+		}
+	}
+}
+
+// seatsFreed is called whenever seats on a bus are given back, so waiting customers can be promoted
+func seatsFreed(ctx context.Context, busID string) {
+	promoted, err := PromoteWaitlist(ctx, busID)
+	if err != nil {
+		log.Println("Error promoting waitlist of bus", busID, err)
+	} else if promoted > 0 {
+		log.Printf("Promoted %d waitlisted customers on bus %s\n", promoted, busID)
+	}
+}
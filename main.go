@@ -2,9 +2,14 @@ package main
 
 import (
 	configs "busapp/database"
+	helper "busapp/helpers"
 	middleware "busapp/middleware"
 	"busapp/routes"
+	"context"
 	"fmt"
+	"log"
+	"time"
+	_ "time/tzdata" // Stops use IANA time zones, also on hosts without a zoneinfo database
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,6 +17,29 @@ import (
 func main() {
 	fmt.Println("hello worldd")
 	configs.ConnectDB()
+	// Bookings are paid through the configured provider
+	helper.SetupPayments()
+	// Tickets must be signed with the same key on every instance and after restarts
+	helper.SetupTicketKey()
+	// Emails are sent from the configured SMTP account
+	helper.SetupMail()
+
+	// Give trips created before departure instants existed their departure and arrival
+	if _, err := helper.BackfillTripTimes(context.Background()); err != nil {
+		log.Println("Error backfilling trip times:", err)
+	}
+	// Indexes keeping concurrent writers from creating duplicate trips
+	if err := helper.EnsureIndexes(context.Background()); err != nil {
+		log.Println("Error creating indexes:", err)
+	}
+	// Keep the scheduled trips generated for the rolling horizon
+	helper.StartScheduleGenerator(6 * time.Hour)
+	// Give seats of abandoned checkouts back to the buses
+	helper.StartHoldSweeper(time.Minute)
+	// Forget revoked tokens once they expired anyway
+	helper.StartRevocationSweeper(time.Hour)
+	// Push seat map changes to clients following a bus
+	helper.StartSeatFeed()
 
 	r := gin.Default()
 	r.GET("/hello", func(c *gin.Context) {
@@ -22,9 +50,9 @@ func main() {
 	// r.Use(gin.Logger())
 
 	routes.Router(r)
-	r.Use(middleware.Authentication())
-	routes.UserRoutes(r)
-	routes.AdminRoutes(r)
+	authorized := r.Group("", middleware.Authentication())
+	routes.UserRoutes(authorized)
+	routes.AdminRoutes(authorized)
 
 	r.Run(":5000")
 }
@@ -3,6 +3,7 @@ package middleware
 import (
 	helper "busapp/helpers"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,40 +15,69 @@ func Authentication() gin.HandlerFunc {
 		
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
 			c.Abort()
 			return
 		}
 
 		claims, err := helper.ValidateToken(clientToken)
 		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err})
 			c.Abort()
 			return
 		}
 
+		// Tokens can be revoked by logging out or changing the password
+		revoked, revokedErr := helper.IsTokenRevoked(c, claims)
+		if revokedErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
+		if claims.Family != "" {
+			if err := helper.TouchSession(c, claims.Family, c.ClientIP()); err != nil {
+				log.Println("Error updating session", claims.Family, err)
+			}
+		}
+
 		c.Set("email", claims.Email)
 		c.Set("username", claims.Username)
 		c.Set("uid", claims.Uid)
 		c.Set("role", claims.Role)
+		c.Set("jti", claims.Id)
+		c.Set("family", claims.Family)
+		c.Set("token_expires", claims.ExpiresAt)
 
 		c.Next()
 
 	}
 }
 
-// RequireAdmin middleware checks if the user has the "admin" role.
-func RequireAdmin() gin.HandlerFunc {
+// RequirePermission only lets requests through when the role of the logged
+// in user grants the permission. It runs after Authentication.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if the user has the "admin" role.
 		role, exists := c.Get("role")
-		if !exists || role != "admin" {
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		roleName, _ := role.(string)
+		if !helper.HasPermission(roleName, permission) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 			c.Abort()
 			return
 		}
 
-		// User has the required role, continue to the next middleware or route handler.
+		// User has the required permission, continue to the next middleware or route handler.
 		c.Next()
 	}
 }
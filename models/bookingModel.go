@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Booking statuses
+const (
+	BookingPending   = "pending"
+	BookingConfirmed = "confirmed"
+	BookingCancelled = "cancelled"
+	BookingFailed    = "failed"
+)
+
+// Seat release states of a cancelled booking. The seats of a cancelled
+// booking stay pending until they are back on the bus.
+const (
+	SeatsReleasePending   = "pending"
+	SeatsReleaseReleasing = "releasing"
+	SeatsReleaseDone      = "done"
+)
+
+// BookingEvent is an entry in the history of a booking
+type BookingEvent struct {
+	Status string    `json:"status" bson:"status"`
+	Amount float64   `json:"amount" bson:"amount"`
+	Note   string    `json:"note,omitempty" bson:"note,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+// Booking represents seats reserved by a user on a bus
+type Booking struct {
+	ID                primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Booking_id        string             `json:"booking_id" bson:"booking_id"`
+	Bus_id            string             `json:"bus_id" bson:"bus_id"`
+	User_id           string             `json:"user_id" bson:"user_id"`
+	Hold_id           string             `json:"hold_id,omitempty" bson:"hold_id,omitempty"`
+	Seats             int                `json:"seats" bson:"seats"`
+	Seat_numbers      []string           `json:"seat_numbers,omitempty" bson:"seat_numbers,omitempty"`
+	Passengers        []Passenger        `json:"passengers,omitempty" bson:"passengers,omitempty"`
+	From              string             `json:"from" bson:"from"`
+	To                string             `json:"to" bson:"to"`
+	Price             PriceBreakdown     `json:"price" bson:"price"`
+	Amount            float64            `json:"amount" bson:"amount"`
+	Payment_intent_id string             `json:"payment_intent_id,omitempty" bson:"payment_intent_id,omitempty"`
+	Refund_amount     float64            `json:"refund_amount,omitempty" bson:"refund_amount,omitempty"`
+	Status            string             `json:"status" bson:"status"`
+	History           []BookingEvent     `json:"history" bson:"history"`
+	Seats_release     string             `json:"seats_release,omitempty" bson:"seats_release,omitempty"`
+	Cancelled_at      *time.Time         `json:"cancelled_at,omitempty" bson:"cancelled_at,omitempty"`
+	Created_at        time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at        time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// ManifestRow is one passenger seat on the boarding manifest of a bus
+type ManifestRow struct {
+	Seat_no        string `json:"seat_no"`
+	Passenger      string `json:"passenger"`
+	Phone          string `json:"phone"`
+	Boarding_point string `json:"boarding_point"`
+	Booking_id     string `json:"booking_id"`
+}
+
+// Passenger concessions
+const (
+	ConcessionChild  = "child"
+	ConcessionSenior = "senior"
+)
+
+// Passenger is the traveller on one seat of an order
+type Passenger struct {
+	Seat_no    string  `json:"seat_no" bson:"seat_no"`
+	Name       string  `json:"name" bson:"name"`
+	Age        int     `json:"age" bson:"age"`
+	Gender     string  `json:"gender" bson:"gender"`
+	Id_type    string  `json:"id_type" bson:"id_type"`
+	Id_number  string  `json:"id_number,omitempty" bson:"id_number,omitempty"`
+	Concession string  `json:"concession,omitempty" bson:"concession,omitempty"`
+	Fare       float64 `json:"fare" bson:"fare"`
+}
@@ -6,13 +6,131 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Availability represents the availability information for a bus on a specific date
+// Seat states
+const (
+	SeatFree   = "free"
+	SeatHeld   = "held"
+	SeatBooked = "booked"
+)
+
+// Trip statuses. A trip is scheduled, then boarding, departed and finally
+// arrived; it may be delayed before it departs and cancelled until then.
+const (
+	TripScheduled = "scheduled"
+	TripDelayed   = "delayed"
+	TripBoarding  = "boarding"
+	TripDeparted  = "departed"
+	TripArrived   = "arrived"
+	TripCancelled = "cancelled"
+)
+
+// TripStatusEvent records a single status change of a trip
+type TripStatusEvent struct {
+	From          string    `json:"from" bson:"from"`
+	Status        string    `json:"status" bson:"status"`
+	Delay_minutes int       `json:"delay_minutes,omitempty" bson:"delay_minutes,omitempty"`
+	Note          string    `json:"note,omitempty" bson:"note,omitempty"`
+	By            string    `json:"by,omitempty" bson:"by,omitempty"`
+	At            time.Time `json:"at" bson:"at"`
+}
+
+// Seat layout types
+const (
+	LayoutSeater  = "seater"
+	LayoutSleeper = "sleeper"
+)
+
+// SeatLayout describes how the seats of a bus are arranged
+type SeatLayout struct {
+	Type        string `json:"type" bson:"type"`
+	Rows        int    `json:"rows" bson:"rows"`
+	Columns     int    `json:"columns" bson:"columns"`
+	Aisle_after int    `json:"aisle_after" bson:"aisle_after"`
+	Decks       int    `json:"decks" bson:"decks"`
+}
+
+// Seat is a single numbered seat (or berth) on a bus
+type Seat struct {
+	Seat_no string `json:"seat_no" bson:"seat_no"`
+	Deck    string `json:"deck,omitempty" bson:"deck,omitempty"`
+	Row     int    `json:"row" bson:"row"`
+	Column  int    `json:"column" bson:"column"`
+	Status  string `json:"status" bson:"status"`
+	Hold_id string `json:"-" bson:"hold_id,omitempty"`
+}
+
+// Availability represents the availability information for a bus on a specific date.
+// Date and Departure_time are local to the first stop of the route, Departure_at
+// and Arrival_at are the instants the trip leaves its first and reaches its last stop.
 type Bus struct {
-	ID          primitive.ObjectID `bson:"_id"`
-	Bus_id      string             `json:"bus_id" bson:"bus_id"`
-	Date        string             `json:"date" bson:"date"`
-	SeatsTotal  int                `json:"seats_total" bson:"seats_total"`
-	SeatsBooked int                `json:"seats_booked" bson:"seats_booked"`
-	Created_at  time.Time          `json:"created_at" bson:"created_at"`
-	Updated_at  time.Time          `json:"updated_at" bson:"updated_at"`
+	ID             primitive.ObjectID `bson:"_id"`
+	Bus_id         string             `json:"bus_id" bson:"bus_id"`
+	Route_id       string             `json:"route_id" bson:"route_id"`
+	Schedule_id    string             `json:"schedule_id,omitempty" bson:"schedule_id,omitempty"`
+	Origin         string             `json:"origin" bson:"origin"`
+	Destination    string             `json:"destination" bson:"destination"`
+	Date           string             `json:"date" bson:"date"`
+	Departure_time string             `json:"departure_time" bson:"departure_time"`
+	Departure_at   time.Time          `json:"departure_at" bson:"departure_at"`
+	Arrival_at     time.Time          `json:"arrival_at" bson:"arrival_at"`
+	Bus_type       string             `json:"bus_type" bson:"bus_type"`
+	Status         string             `json:"status" bson:"status"`
+	Status_history []TripStatusEvent  `json:"status_history,omitempty" bson:"status_history,omitempty"`
+	Delay_minutes  int                `json:"delay_minutes" bson:"delay_minutes"`
+	Fare           float64            `json:"fare" bson:"fare"`
+	SeatsTotal     int                `json:"seats_total" bson:"seats_total"`
+	SeatsBooked    int                `json:"seats_booked" bson:"seats_booked"`
+	SeatsHeld      int                `json:"seats_held" bson:"seats_held"`
+	Layout         SeatLayout         `json:"layout" bson:"layout"`
+	Seats          []Seat             `json:"seats,omitempty" bson:"seats,omitempty"`
+	Created_at     time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at     time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// TripResult is a single bus trip returned by the trip search, seen from the
+// boarding stop to the alighting stop of the passenger
+type TripResult struct {
+	Bus_id           string         `json:"bus_id"`
+	Route_id         string         `json:"route_id"`
+	From             string         `json:"from"`
+	To               string         `json:"to"`
+	Bus_type         string         `json:"bus_type"`
+	Departure_at     time.Time      `json:"departure_at"`
+	Arrival_at       time.Time      `json:"arrival_at"`
+	Duration_minutes int            `json:"duration_minutes"`
+	Fare             float64        `json:"fare"`
+	Total_fare       float64        `json:"total_fare"`
+	Price            PriceBreakdown `json:"price"`
+	Seats_available  int            `json:"seats_available"`
+}
+
+// BusSummary is a single row of the admin bus listing
+type BusSummary struct {
+	Bus_id         string    `json:"bus_id" bson:"bus_id"`
+	Route_id       string    `json:"route_id" bson:"route_id"`
+	Origin         string    `json:"origin" bson:"origin"`
+	Destination    string    `json:"destination" bson:"destination"`
+	Date           string    `json:"date" bson:"date"`
+	Departure_time string    `json:"departure_time" bson:"departure_time"`
+	Departure_at   time.Time `json:"departure_at" bson:"departure_at"`
+	Arrival_at     time.Time `json:"arrival_at" bson:"arrival_at"`
+	Bus_type       string    `json:"bus_type" bson:"bus_type"`
+	Status         string    `json:"status" bson:"status"`
+	Fare           float64   `json:"fare" bson:"fare"`
+	SeatsTotal     int       `json:"seats_total" bson:"seats_total"`
+	SeatsBooked    int       `json:"seats_booked" bson:"seats_booked"`
+	SeatsHeld      int       `json:"seats_held" bson:"seats_held"`
+	Load_factor    float64   `json:"load_factor" bson:"load_factor"`
+	Revenue        float64   `json:"revenue" bson:"-"`
+	Created_at     time.Time `json:"created_at" bson:"created_at"`
+}
+
+// SeatUpdate is pushed to clients following the seat map of a bus. The first
+// update holds every seat, later ones only the seats whose status changed.
+type SeatUpdate struct {
+	Bus_id          string    `json:"bus_id"`
+	Status          string    `json:"status"`
+	Seats_available int       `json:"seats_available"`
+	Seats           []Seat    `json:"seats"`
+	At              time.Time `json:"at"`
 }
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pricing rule types
+const (
+	RuleWeekendSurcharge = "weekend_surcharge"
+	RuleHolidaySurcharge = "holiday_surcharge"
+	RuleOccupancy        = "occupancy"
+	RuleEarlyBird        = "early_bird"
+)
+
+// Fare is the base price of one seat of a class between two cities of a route
+type Fare struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Fare_id    string             `json:"fare_id" bson:"fare_id"`
+	Route_id   string             `json:"route_id" bson:"route_id"`
+	From       string             `json:"from" bson:"from"`
+	To         string             `json:"to" bson:"to"`
+	Seat_class string             `json:"seat_class" bson:"seat_class"`
+	Base_fare  float64            `json:"base_fare" bson:"base_fare"`
+	Created_at time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// PricingRule adjusts the base fare by Percent when it applies. Surcharges
+// raise the price, early-bird discounts lower it. Rules without a route apply to every route.
+type PricingRule struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Rule_id       string             `json:"rule_id" bson:"rule_id"`
+	Name          string             `json:"name" bson:"name"`
+	Type          string             `json:"type" bson:"type"`
+	Route_id      string             `json:"route_id,omitempty" bson:"route_id,omitempty"`
+	Percent       float64            `json:"percent" bson:"percent"`
+	Holidays      []string           `json:"holidays,omitempty" bson:"holidays,omitempty"`
+	Min_occupancy int                `json:"min_occupancy,omitempty" bson:"min_occupancy,omitempty"`
+	Days_before   int                `json:"days_before,omitempty" bson:"days_before,omitempty"`
+	Active        bool               `json:"active" bson:"active"`
+	Created_at    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// PriceItem is one line of a price breakdown
+type PriceItem struct {
+	Label  string  `json:"label" bson:"label"`
+	Amount float64 `json:"amount" bson:"amount"`
+}
+
+// PriceBreakdown is the itemized price of a number of seats
+type PriceBreakdown struct {
+	Base_fare float64     `json:"base_fare" bson:"base_fare"`
+	Items     []PriceItem `json:"items" bson:"items"`
+	Per_seat  float64     `json:"per_seat" bson:"per_seat"`
+	Seats     int         `json:"seats" bson:"seats"`
+	Total     float64     `json:"total" bson:"total"`
+}
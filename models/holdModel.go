@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hold statuses
+const (
+	HoldActive    = "active"
+	HoldConverted = "converted"
+	HoldReleased  = "released"
+	HoldExpired   = "expired"
+)
+
+// Hold keeps seats on a bus reserved for a user between picking them and checkout
+type Hold struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Hold_id       string             `json:"hold_id" bson:"hold_id"`
+	Bus_id        string             `json:"bus_id" bson:"bus_id"`
+	User_id       string             `json:"user_id" bson:"user_id"`
+	Seats         int                `json:"seats" bson:"seats"`
+	Seat_numbers  []string           `json:"seat_numbers,omitempty" bson:"seat_numbers,omitempty"`
+	Passengers    []Passenger        `json:"passengers,omitempty" bson:"passengers,omitempty"`
+	From          string             `json:"from" bson:"from"`
+	To            string             `json:"to" bson:"to"`
+	Price         PriceBreakdown     `json:"price" bson:"price"`
+	Amount        float64            `json:"amount" bson:"amount"`
+	Status        string             `json:"status" bson:"status"`
+	Seats_release string             `json:"-" bson:"seats_release,omitempty"`
+	Expires_at    time.Time          `json:"expires_at" bson:"expires_at"`
+	Created_at    time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at    time.Time          `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// Kinds of records that can be bulk imported
+const (
+	ImportBuses     = "buses"
+	ImportSchedules = "schedules"
+)
+
+// ImportRowError reports why a single row of an import file was rejected.
+// Rows are numbered from 1, not counting the CSV header.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes a bulk import
+type ImportResult struct {
+	Kind       string           `json:"kind"`
+	Dry_run    bool             `json:"dry_run"`
+	Rows       int              `json:"rows"`
+	Valid      int              `json:"valid"`
+	Inserted   int              `json:"inserted"`
+	Duplicates []int            `json:"duplicates"`
+	Errors     []ImportRowError `json:"errors"`
+}
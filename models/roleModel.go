@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles a user can have
+const (
+	RoleCustomer = "customer"
+	RoleAgent    = "agent"
+	RoleSupport  = "support"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// Permissions granted by roles
+const (
+	PermBookTrips       = "trips:book"
+	PermOperateTrips    = "trips:operate"
+	PermViewBuses       = "buses:view"
+	PermManageBuses     = "buses:manage"
+	PermViewManifests   = "manifests:view"
+	PermManageRoutes    = "routes:manage"
+	PermManageRefunds   = "refundpolicy:manage"
+	PermManageSchedules = "schedules:manage"
+	PermManagePricing   = "pricing:manage"
+	PermViewUsers       = "users:view"
+	PermManageUsers     = "users:manage"
+)
+
+// Role change statuses. A role change is recorded as pending before the role
+// is changed, so no role is ever given without an audit record.
+const (
+	RoleChangePending = "pending"
+	RoleChangeApplied = "applied"
+	RoleChangeFailed  = "failed"
+)
+
+// RoleChange is the audit record of a role given to a user, either when an
+// admin creates the user or through a role assignment
+type RoleChange struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	User_id    string             `json:"user_id" bson:"user_id"`
+	From_role  string             `json:"from_role,omitempty" bson:"from_role,omitempty"`
+	To_role    string             `json:"to_role" bson:"to_role"`
+	By         string             `json:"by" bson:"by"`
+	By_role    string             `json:"by_role" bson:"by_role"`
+	Reason     string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Status     string             `json:"status" bson:"status"`
+	Created_at time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Stop is a single stop on a route. Offsets are minutes from the departure of the trip at the first stop.
+// Timezone is the IANA time zone of the stop, e.g. Asia/Kolkata. Stops with
+// coordinates let live tracking work out how far along the route a bus is.
+type Stop struct {
+	Name             string  `json:"name" bson:"name"`
+	City             string  `json:"city" bson:"city"`
+	Timezone         string  `json:"timezone" bson:"timezone"`
+	Latitude         float64 `json:"latitude,omitempty" bson:"latitude,omitempty"`
+	Longitude        float64 `json:"longitude,omitempty" bson:"longitude,omitempty"`
+	Arrival_offset   int     `json:"arrival_offset" bson:"arrival_offset"`
+	Departure_offset int     `json:"departure_offset" bson:"departure_offset"`
+}
+
+// RefundTier refunds Percent of the amount paid when a booking is cancelled
+// at least Hours_before hours before departure
+type RefundTier struct {
+	Hours_before int `json:"hours_before" bson:"hours_before"`
+	Percent      int `json:"percent" bson:"percent"`
+}
+
+// Route is an ordered list of stops that bus trips run along
+type Route struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Route_id      string             `json:"route_id" bson:"route_id"`
+	Name          string             `json:"name" bson:"name"`
+	Origin        string             `json:"origin" bson:"origin"`
+	Destination   string             `json:"destination" bson:"destination"`
+	Stops         []Stop             `json:"stops" bson:"stops"`
+	Refund_policy []RefundTier       `json:"refund_policy,omitempty" bson:"refund_policy,omitempty"`
+	Created_at    time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// RoutePolicy lists a route with the refund policy that applies to it;
+// Default is true when the route has no policy of its own
+type RoutePolicy struct {
+	Route_id      string       `json:"route_id"`
+	Name          string       `json:"name"`
+	Origin        string       `json:"origin"`
+	Destination   string       `json:"destination"`
+	Refund_policy []RefundTier `json:"refund_policy"`
+	Default       bool         `json:"default"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schedule is a recurring template from which dated bus trips are generated,
+// e.g. "every weekday at 21:30 on route X with layout Y"
+type Schedule struct {
+	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Schedule_id    string             `json:"schedule_id" bson:"schedule_id"`
+	Route_id       string             `json:"route_id" bson:"route_id"`
+	Weekdays       []int              `json:"weekdays" bson:"weekdays"` // 0 = Sunday ... 6 = Saturday
+	Departure_time string             `json:"departure_time" bson:"departure_time"`
+	Bus_type       string             `json:"bus_type" bson:"bus_type"`
+	Fare           float64            `json:"fare" bson:"fare"`
+	SeatsTotal     int                `json:"seats_total" bson:"seats_total"`
+	Layout         SeatLayout         `json:"layout" bson:"layout"`
+	Blackout_dates []string           `json:"blackout_dates" bson:"blackout_dates"`
+	Horizon_days   int                `json:"horizon_days" bson:"horizon_days"`
+	Active         bool               `json:"active" bson:"active"`
+	Created_at     time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at     time.Time          `json:"updated_at" bson:"updated_at"`
+}
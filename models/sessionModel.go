@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a single login of a user on a device. It lives as long as the
+// refresh token family started at that login, whose ID it shares.
+type Session struct {
+	ID         primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Session_id string             `json:"session_id" bson:"session_id"`
+	User_id    string             `json:"-" bson:"user_id"`
+	User_agent string             `json:"user_agent" bson:"user_agent"`
+	Ip         string             `json:"ip" bson:"ip"`
+	Issued_at  time.Time          `json:"issued_at" bson:"issued_at"`
+	Last_seen  time.Time          `json:"last_seen" bson:"last_seen"`
+	Expires_at time.Time          `json:"expires_at" bson:"expires_at"`
+	Revoked_at time.Time          `json:"-" bson:"revoked_at,omitempty"`
+	Current    bool               `json:"current" bson:"-"`
+}
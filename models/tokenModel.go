@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Refresh token statuses
+const (
+	RefreshActive  = "active"
+	RefreshUsed    = "used"
+	RefreshRevoked = "revoked"
+)
+
+// RefreshToken is a long-lived token exchanged for a new access token. Only a
+// hash of the token is stored. Every refresh uses up the token and issues the
+// next one in the same family, which starts at login.
+type RefreshToken struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Token_hash string             `json:"-" bson:"token_hash"`
+	Family_id  string             `json:"family_id" bson:"family_id"`
+	User_id    string             `json:"user_id" bson:"user_id"`
+	Status     string             `json:"status" bson:"status"`
+	Expires_at time.Time          `json:"expires_at" bson:"expires_at"`
+	Used_at    time.Time          `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	Created_at time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// TokenPair is what a login or refresh hands out
+type TokenPair struct {
+	Token         string    `json:"token"`
+	Refresh_token string    `json:"refresh_token"`
+	Expires_at    time.Time `json:"expires_at"`
+	Session_id    string    `json:"session_id"`
+}
+
+// RevokedToken revokes either a single access token by its jti, or the
+// access tokens of a session by its refresh token family, until they expire;
+// or every token issued to a user before Revoked_before (unix milliseconds)
+type RevokedToken struct {
+	ID             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Jti            string             `json:"jti,omitempty" bson:"jti,omitempty"`
+	Family         string             `json:"family,omitempty" bson:"family,omitempty"`
+	User_id        string             `json:"user_id" bson:"user_id"`
+	Revoked_before int64              `json:"revoked_before,omitempty" bson:"revoked_before,omitempty"`
+	Expires_at     time.Time          `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	Created_at     time.Time          `json:"created_at" bson:"created_at"`
+}
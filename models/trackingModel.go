@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ping is a single position reported by the device on a bus. Speed is in km/h.
+type Ping struct {
+	Latitude    float64   `json:"latitude" bson:"latitude"`
+	Longitude   float64   `json:"longitude" bson:"longitude"`
+	Speed       float64   `json:"speed" bson:"speed"`
+	Recorded_at time.Time `json:"recorded_at" bson:"recorded_at"`
+	Received_at time.Time `json:"received_at" bson:"received_at"`
+}
+
+// Track holds the most recent pings of a trip, oldest first
+type Track struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Bus_id     string             `json:"bus_id" bson:"bus_id"`
+	Pings      []Ping             `json:"pings" bson:"pings"`
+	Updated_at time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// StopEta is when a trip was scheduled and is now expected at a stop
+type StopEta struct {
+	Name         string    `json:"name"`
+	City         string    `json:"city"`
+	Scheduled_at time.Time `json:"scheduled_at"`
+	Expected_at  time.Time `json:"expected_at"`
+	Passed       bool      `json:"passed"`
+}
+
+// TripPosition is where a trip is and when it is expected at its remaining stops
+type TripPosition struct {
+	Bus_id        string    `json:"bus_id"`
+	Status        string    `json:"status"`
+	Position      *Ping     `json:"position"`
+	Delay_minutes int       `json:"delay_minutes"`
+	Stops         []StopEta `json:"stops"`
+}
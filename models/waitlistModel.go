@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Waitlist statuses
+const (
+	WaitlistWaiting  = "waiting"
+	WaitlistPromoted = "promoted"
+)
+
+// WaitlistEntry is a customer queued for seats on a sold-out bus
+type WaitlistEntry struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Waitlist_id string             `json:"waitlist_id" bson:"waitlist_id"`
+	Bus_id      string             `json:"bus_id" bson:"bus_id"`
+	User_id     string             `json:"user_id" bson:"user_id"`
+	Email       string             `json:"email" bson:"email"`
+	Seats       int                `json:"seats" bson:"seats"`
+	From        string             `json:"from,omitempty" bson:"from,omitempty"`
+	To          string             `json:"to,omitempty" bson:"to,omitempty"`
+	Status      string             `json:"status" bson:"status"`
+	Hold_id     string             `json:"hold_id,omitempty" bson:"hold_id,omitempty"`
+	Created_at  time.Time          `json:"created_at" bson:"created_at"`
+	Updated_at  time.Time          `json:"updated_at" bson:"updated_at"`
+}
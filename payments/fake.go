@@ -0,0 +1,131 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FakeProvider is a deterministic in-process payment provider, so the whole
+// booking and payment flow can be exercised offline. Intent ids are numbered
+// in creation order and webhooks are signed with HMAC-SHA256.
+type FakeProvider struct {
+	mu      sync.Mutex
+	secret  []byte
+	next    int
+	intents map[string]*Intent
+}
+
+// NewFakeProvider returns a fake provider signing its webhooks with secret
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: []byte(secret), intents: make(map[string]*Intent)}
+}
+
+// CreateIntent records a pending intent
+func (p *FakeProvider) CreateIntent(ctx context.Context, amount float64, currency string, reference string) (*Intent, error) {
+	if amount < 0 {
+		return nil, fmt.Errorf("amount cannot be negative")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next++
+	intent := &Intent{
+		ID:        fmt.Sprintf("fake_pi_%06d", p.next),
+		Reference: reference,
+		Amount:    amount,
+		Currency:  currency,
+		Status:    IntentPending,
+	}
+	p.intents[intent.ID] = intent
+
+	copied := *intent
+	return &copied, nil
+}
+
+// Capture marks a pending intent as paid
+func (p *FakeProvider) Capture(ctx context.Context, intentID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return fmt.Errorf("unknown payment intent %s", intentID)
+	}
+	if intent.Status != IntentPending {
+		return fmt.Errorf("payment intent %s is %s", intentID, intent.Status)
+	}
+	intent.Status = IntentCaptured
+	return nil
+}
+
+// Refund gives back part or all of a captured intent
+func (p *FakeProvider) Refund(ctx context.Context, intentID string, amount float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return fmt.Errorf("unknown payment intent %s", intentID)
+	}
+	if intent.Status != IntentCaptured {
+		return fmt.Errorf("payment intent %s is %s", intentID, intent.Status)
+	}
+	if amount < 0 || amount > intent.Amount {
+		return fmt.Errorf("refund of %.2f is more than the %.2f paid", amount, intent.Amount)
+	}
+	intent.Status = IntentRefunded
+	return nil
+}
+
+// Sign returns the signature the fake provider puts on a webhook payload
+func (p *FakeProvider) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks the HMAC signature of a payload and decodes its event
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if !hmac.Equal([]byte(p.Sign(payload)), []byte(signature)) {
+		return nil, fmt.Errorf("invalid webhook signature")
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	if event.Type != EventPaymentSucceeded && event.Type != EventPaymentFailed {
+		return nil, fmt.Errorf("unknown webhook event %q", event.Type)
+	}
+	return &event, nil
+}
+
+// Complete simulates the customer finishing (or failing) a payment and
+// returns the signed webhook the provider would send for it
+func (p *FakeProvider) Complete(intentID string, succeeded bool) ([]byte, string, error) {
+	p.mu.Lock()
+	intent, ok := p.intents[intentID]
+	if ok && !succeeded && intent.Status == IntentPending {
+		intent.Status = IntentFailed
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unknown payment intent %s", intentID)
+	}
+
+	event := WebhookEvent{Type: EventPaymentFailed, Intent_id: intent.ID, Reference: intent.Reference}
+	if succeeded {
+		event.Type = EventPaymentSucceeded
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.Sign(payload), nil
+}
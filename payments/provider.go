@@ -0,0 +1,58 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+)
+
+// Intent statuses
+const (
+	IntentPending  = "pending"
+	IntentCaptured = "captured"
+	IntentFailed   = "failed"
+	IntentRefunded = "refunded"
+)
+
+// Webhook event types
+const (
+	EventPaymentSucceeded = "payment_succeeded"
+	EventPaymentFailed    = "payment_failed"
+)
+
+// Intent is a payment the customer is asked to make
+type Intent struct {
+	ID        string  `json:"id"`
+	Reference string  `json:"reference"`
+	Amount    float64 `json:"amount"`
+	Currency  string  `json:"currency"`
+	Status    string  `json:"status"`
+}
+
+// WebhookEvent is a verified notification from the payment provider
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	Intent_id string `json:"intent_id"`
+	Reference string `json:"reference"`
+}
+
+// PaymentProvider is implemented by every payment gateway the app can take payments through
+type PaymentProvider interface {
+	// CreateIntent starts a payment of amount for the given reference (such as a booking id)
+	CreateIntent(ctx context.Context, amount float64, currency string, reference string) (*Intent, error)
+	// Capture takes the money of an authorized intent
+	Capture(ctx context.Context, intentID string) error
+	// Refund gives back part or all of a captured intent
+	Refund(ctx context.Context, intentID string, amount float64) error
+	// VerifyWebhook checks the signature of a webhook payload and decodes it
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// New returns the payment provider with the given name. The in-process fake
+// provider is used when no name is given.
+func New(name string, webhookSecret string) (PaymentProvider, error) {
+	switch name {
+	case "", "fake":
+		return NewFakeProvider(webhookSecret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
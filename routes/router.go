@@ -4,31 +4,103 @@ import (
 	"github.com/gin-gonic/gin"
 
 	controller "busapp/controllers"
+	helper "busapp/helpers"
+	middleware "busapp/middleware"
+	"busapp/models"
 )
 
 // UserRoutes function
 func Router(incomingRoutes *gin.Engine) {
 	incomingRoutes.POST("/signup", controller.SignUp())
 	incomingRoutes.POST("/login", controller.Login())
+	incomingRoutes.POST("/token/refresh", controller.RefreshToken)
 	incomingRoutes.POST("/Forgetpassword", controller.HandleForgetPassword) // by using token
 	incomingRoutes.POST("ResetPassword", controller.HandleResetPassword)    // by using token
 	incomingRoutes.POST("/forgetpassword", controller.ForgetPassword)       //by using otp
 	incomingRoutes.POST("/resetpassword", controller.ResetPasswordWithOTP)  //by using otp
+	incomingRoutes.GET("/buses/search", controller.SearchTrips)
+	incomingRoutes.GET("/buses/seats/stream", controller.StreamSeatMap)
+	incomingRoutes.POST("/payments/webhook", controller.PaymentWebhook)
+	incomingRoutes.GET("/tickets/publickey", controller.GetTicketPublicKey)
 }
 
-// UserRoutes function
-func UserRoutes(incomingRoutes *gin.Engine) {
+// UserRoutes registers the routes every logged in user may call, and the
+// booking routes for the roles that may book
+func UserRoutes(incomingRoutes *gin.RouterGroup) {
 	incomingRoutes.PATCH("/edituser", controller.UpdateUserDetailsHandler)
 	incomingRoutes.GET("/me", controller.GetMyDetails)
+	incomingRoutes.POST("/logout", controller.Logout)
+	incomingRoutes.GET("/me/sessions", controller.GetMySessions)
+	incomingRoutes.DELETE("/me/sessions", controller.RevokeMySession)
+	incomingRoutes.DELETE("/me/sessions/others", controller.RevokeMyOtherSessions)
 	incomingRoutes.GET("helloall", controller.Hello)
+	incomingRoutes.GET("/seatmap", controller.GetSeatMap)
+	incomingRoutes.GET("/tracking", controller.GetTripTracking)
+
+	booking := incomingRoutes.Group("", middleware.RequirePermission(models.PermBookTrips))
+	booking.POST("/holds", controller.HoldSeats)
+	booking.DELETE("/holds", controller.ReleaseSeatHold)
+	booking.POST("/bookings", controller.BookSeats)
+	booking.POST("/bookings/cancel", controller.CancelBooking)
+	booking.GET("/tickets/qr", controller.GetTicketQR)
+	booking.GET("/tickets/pdf", controller.GetTicketPDF)
+	booking.POST("/waitlist", controller.JoinWaitlist)
+	booking.GET("/waitlist", controller.GetWaitlistPosition)
+	if helper.FakePaymentsEnabled() {
+		booking.POST("/payments/fake/complete", controller.CompleteFakePayment)
+	}
 }
 
-// UserRoutes function
-func AdminRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/admin/adduser", controller.Adduser)
-	incomingRoutes.DELETE("/admin/deleteuser", controller.AdminDeleteUser)
-	incomingRoutes.GET("/admin/getcustomers", controller.AdminGetAllCustomers)
-	incomingRoutes.GET("/admin/getallusers", controller.AdminGetAllUsers)
-	incomingRoutes.POST("/admin/addBus", controller.AddBus)
+// AdminRoutes registers the back office routes, each group open to the roles granting its permission
+func AdminRoutes(incomingRoutes *gin.RouterGroup) {
+	users := incomingRoutes.Group("", middleware.RequirePermission(models.PermViewUsers))
+	users.GET("/admin/getcustomers", controller.AdminGetAllCustomers)
+	users.GET("/admin/getallusers", controller.AdminGetAllUsers)
+
+	manageUsers := incomingRoutes.Group("", middleware.RequirePermission(models.PermManageUsers))
+	manageUsers.POST("/admin/adduser", controller.Adduser)
+	manageUsers.DELETE("/admin/deleteuser", controller.AdminDeleteUser)
+	manageUsers.PUT("/admin/userrole", controller.AdminAssignRole)
+	manageUsers.GET("/admin/rolechanges", controller.AdminGetRoleChanges)
+
+	buses := incomingRoutes.Group("", middleware.RequirePermission(models.PermViewBuses))
+	buses.GET("/admin/buses", controller.AdminGetAllBuses)
+
+	manageBuses := incomingRoutes.Group("", middleware.RequirePermission(models.PermManageBuses))
+	manageBuses.POST("/admin/addBus", controller.AddBus)
+	manageBuses.POST("/admin/import", controller.AdminImport)
+	manageBuses.PATCH("/admin/editbus", controller.EditBus)
+	manageBuses.POST("/admin/cancelbus", controller.AdminCancelBus)
+	manageBuses.DELETE("/admin/deletebus", controller.AdminDeleteBus)
+
+	trips := incomingRoutes.Group("", middleware.RequirePermission(models.PermOperateTrips))
+	trips.POST("/admin/tripstatus", controller.UpdateTripStatus)
+	trips.POST("/tracking/ping", controller.RecordTripPing)
+	trips.POST("/tickets/verify", controller.VerifyTicket)
+
+	manifests := incomingRoutes.Group("", middleware.RequirePermission(models.PermViewManifests))
+	manifests.GET("/admin/manifest", controller.AdminGetManifest)
+
+	routes := incomingRoutes.Group("", middleware.RequirePermission(models.PermManageRoutes))
+	routes.POST("/admin/addRoute", controller.AddRoute)
+	routes.GET("/admin/routes", controller.AdminGetAllRoutes)
+	routes.PATCH("/admin/editroute", controller.EditRoute)
+	routes.DELETE("/admin/deleteroute", controller.AdminDeleteRoute)
+
+	refunds := incomingRoutes.Group("", middleware.RequirePermission(models.PermManageRefunds))
+	refunds.GET("/admin/refundpolicy", controller.AdminGetRefundPolicies)
+	refunds.PUT("/admin/refundpolicy", controller.SetRouteRefundPolicy)
+
+	pricing := incomingRoutes.Group("", middleware.RequirePermission(models.PermManagePricing))
+	pricing.POST("/admin/addFare", controller.AddFare)
+	pricing.GET("/admin/fares", controller.AdminGetFares)
+	pricing.POST("/admin/addPricingRule", controller.AddPricingRule)
+	pricing.GET("/admin/pricingrules", controller.AdminGetPricingRules)
+	pricing.DELETE("/admin/deletepricingrule", controller.AdminDeletePricingRule)
+
+	schedules := incomingRoutes.Group("", middleware.RequirePermission(models.PermManageSchedules))
+	schedules.POST("/admin/addSchedule", controller.AddSchedule)
+	schedules.GET("/admin/schedules", controller.AdminGetAllSchedules)
+	schedules.POST("/admin/generatetrips", controller.GenerateScheduledTrips)
 	// incomingRoutes.GET("helloall", controller.Hello)
 }